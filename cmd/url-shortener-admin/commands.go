@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/lib/alias"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/storage"
+)
+
+const (
+	// aliasAttempts is how many generated aliases are tried before giving up.
+	aliasAttempts = 5
+	// defaultListLimit is how many urls list prints unless told otherwise.
+	defaultListLimit = 50
+)
+
+// runCreate saves url, validated the way POST /url validates it.
+func runCreate(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet("create")
+	req := save.Request{}
+	fs.StringVar(&req.Alias, "alias", "", "alias, generated if empty")
+	fs.StringVar(&req.TTL, "ttl", "", "lifetime of the url, e.g. 72h")
+	fs.StringVar(&req.AliasStrategy, "strategy", env.cfg.Alias.Strategy, "strategy of alias generation")
+	fs.IntVar(&req.AliasLength, "length", env.cfg.Alias.Length, "length of generated alias")
+	fs.IntVar(&req.RedirectType, "redirect", 0, "redirect status: 301, 302, 307 or 308, configured one by default")
+	fs.StringVar(&req.Password, "password", "", "password asked for before redirecting")
+	fs.Int64Var(&req.MaxClicks, "max-clicks", 0, "number of redirects before the url is gone, unlimited by default")
+	fs.StringVar(&req.Title, "title", "", "title shown on preview page")
+	fs.BoolVar(&req.Preview, "preview", false, "show preview page before redirecting")
+	fs.StringVar(&req.Domain, "domain", "", "one of configured domains, the primary one by default")
+	userID := fs.Int64("user", 0, "owner of the url")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	req.URL = fs.Arg(0)
+
+	if err := validator.New().Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		if errors.As(err, &validateErr) {
+			return errors.New(resp.ValidationError(validateErr).Error)
+		}
+		return err
+	}
+
+	if req.Alias != "" {
+		if err := alias.Validate(req.Alias); err != nil {
+			return err
+		}
+	}
+
+	if !domain.NewAllowlist(env.cfg.Domains).Allows(req.Domain) {
+		return save.ErrDomainNotAllowed
+	}
+
+	expiresAt, err := save.ParseExpiration(nil, req.TTL, time.Now())
+	if err != nil {
+		return err
+	}
+	opts := storage.URLOptions{
+		ExpiresAt:    expiresAt,
+		RedirectType: req.RedirectType,
+		MaxClicks:    req.MaxClicks,
+		Title:        req.Title,
+		Preview:      req.Preview,
+		Domain:       domain.Normalize(req.Domain),
+	}
+	if req.Password != "" {
+		if opts.PasswordHash, err = password.Hash(req.Password); err != nil {
+			return err
+		}
+	}
+
+	if req.Alias != "" {
+		if _, err = env.storage.SaveURL(ctx, req.URL, req.Alias, *userID, opts); err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(env.out, req.Alias)
+
+		return err
+	}
+
+	gen, ok := alias.NewStrategies(env.storage, env.cfg.Alias.Salt)[req.AliasStrategy]
+	if !ok {
+		return fmt.Errorf("unknown alias strategy %q", req.AliasStrategy)
+	}
+
+	for i := 0; i < aliasAttempts; i++ {
+		a, err := gen.Generate(ctx, req.AliasLength)
+		if err != nil {
+			return err
+		}
+
+		_, err = env.storage.SaveURL(ctx, req.URL, a, *userID, opts)
+		if errors.Is(err, storage.ErrURLExists) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(env.out, a)
+
+		return err
+	}
+
+	return save.ErrNoFreeAlias
+}
+
+// runGet prints the url with given alias, expired or not.
+func runGet(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet("get")
+	urlDomain := domainFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	u, err := env.storage.GetURLInfo(ctx, domain.Normalize(*urlDomain), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return printURLs(env.out, []storage.URL{u})
+}
+
+func runDelete(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet("delete")
+	urlDomain := domainFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	if err := env.storage.DeleteURL(ctx, domain.Normalize(*urlDomain), fs.Arg(0)); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(env.out, "deleted %s\n", fs.Arg(0))
+
+	return err
+}
+
+// runList prints the oldest urls of all users or of one user.
+func runList(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet("list")
+	userID := fs.Int64("user", 0, "list urls of this user only")
+	limit := fs.Int("limit", defaultListLimit, "maximum number of urls")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *limit < 1 {
+		return errUsage
+	}
+
+	filter := storage.ExportFilter{Limit: *limit}
+	if *userID != 0 {
+		filter.UserID = userID
+	}
+
+	urls, err := env.storage.ExportURLs(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return printURLs(env.out, urls)
+}
+
+// runRename changes alias of url, its clicks are kept. The url stays on
+// its domain.
+func runRename(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet("rename")
+	urlDomain := domainFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 || fs.Arg(1) == "" {
+		return errUsage
+	}
+
+	if err := alias.Validate(fs.Arg(1)); err != nil {
+		return err
+	}
+
+	if err := env.storage.RenameURL(ctx, domain.Normalize(*urlDomain), fs.Arg(0), fs.Arg(1)); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(env.out, "renamed %s to %s\n", fs.Arg(0), fs.Arg(1))
+
+	return err
+}
+
+// runPurge deletes expired urls like the janitor of the server does,
+// urls expired within the configured retention are kept.
+func runPurge(ctx context.Context, env *env, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	deleted, err := env.storage.DeleteExpiredURLs(ctx, time.Now().Add(-env.cfg.Janitor.Retention))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(env.out, "deleted %d expired urls\n", deleted)
+
+	return err
+}
+
+// runStats prints clicks of url grouped by hour or day.
+func runStats(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet("stats")
+	interval := fs.String("interval", "day", "size of a bucket: hour or day")
+	fromFlag := fs.String("from", "", "RFC 3339 start of the range, a day or 30 days ago by default")
+	toFlag := fs.String("to", "", "RFC 3339 end of the range, now by default")
+	urlDomain := domainFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	var bucket, period time.Duration
+	switch *interval {
+	case "hour":
+		bucket, period = time.Hour, 24*time.Hour
+	case "day":
+		bucket, period = 24*time.Hour, 30*24*time.Hour
+	default:
+		return errUsage
+	}
+
+	to := time.Now().UTC()
+	if *toFlag != "" {
+		t, err := time.Parse(time.RFC3339, *toFlag)
+		if err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+		to = t.UTC()
+	}
+
+	from := to.Add(-period)
+	if *fromFlag != "" {
+		t, err := time.Parse(time.RFC3339, *fromFlag)
+		if err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+		from = t.UTC()
+	}
+
+	if !from.Before(to) {
+		return errors.New("-from must be before -to")
+	}
+
+	stats, err := env.storage.GetClickStats(ctx, domain.Normalize(*urlDomain), fs.Arg(0), from, to, bucket)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(env.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "total\t%d\n", stats.Total)
+	for _, b := range stats.Buckets {
+		fmt.Fprintf(w, "%s\t%d\n", b.Start.Format(time.RFC3339), b.Count)
+	}
+
+	return w.Flush()
+}
+
+func printURLs(out io.Writer, urls []storage.URL) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALIAS\tURL\tUSER\tCREATED\tEXPIRES\tCLICKS\tREDIRECT")
+	for _, u := range urls {
+		expires := "never"
+		if u.ExpiresAt != nil {
+			expires = u.ExpiresAt.Format(time.RFC3339)
+		}
+
+		redirect := "default"
+		if u.RedirectType != 0 {
+			redirect = strconv.Itoa(u.RedirectType)
+		}
+
+		// urls on other domains than the primary one are told apart by it
+		a := u.Alias
+		if u.Domain != "" {
+			a = u.Domain + "/" + u.Alias
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%d\t%s\n",
+			a, u.URL, u.UserID, u.CreatedAt.UTC().Format(time.RFC3339), expires, u.Clicks, redirect)
+	}
+
+	return w.Flush()
+}
+
+// newFlagSet returns flag set of command that reports errors
+// by returning them, so usage is printed once.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	return fs
+}
+
+// domainFlag adds -domain flag selecting url on one of configured domains.
+func domainFlag(fs *flag.FlagSet) *string {
+	return fs.String("domain", "", "domain of the url, the primary one by default")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+)
+
+func newTestEnv() *env {
+	return &env{
+		cfg: &config.Config{
+			Alias: config.AliasConfig{Strategy: alias.StrategyRandom, Length: 6},
+		},
+		storage: memory.New(),
+		out:     &bytes.Buffer{},
+	}
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name  string
+		alias string
+		err   error
+	}{
+		{name: "Custom alias", alias: "google"},
+		{name: "Alias of preview page", alias: "google+", err: alias.ErrInvalid},
+		{name: "Reserved alias", alias: "healthz", err: alias.ErrReserved},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			env := newTestEnv()
+
+			err := runCreate(context.Background(), env, []string{"-alias", tc.alias, "https://google.com"})
+			require.ErrorIs(t, err, tc.err)
+			if tc.err != nil {
+				return
+			}
+
+			u, err := env.storage.GetURL(context.Background(), "", tc.alias)
+			require.NoError(t, err)
+			require.Equal(t, "https://google.com", u.URL)
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	cases := []struct {
+		name     string
+		newAlias string
+		err      error
+	}{
+		{name: "Valid alias", newAlias: "renamed"},
+		{name: "Alias of preview page", newAlias: "renamed+", err: alias.ErrInvalid},
+		{name: "Reserved alias", newAlias: "metrics", err: alias.ErrReserved},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			env := newTestEnv()
+
+			_, err := env.storage.SaveURL(context.Background(), "https://google.com", "google", 1, storage.URLOptions{})
+			require.NoError(t, err)
+
+			err = runRename(context.Background(), env, []string{"google", tc.newAlias})
+			require.ErrorIs(t, err, tc.err)
+
+			// the url keeps its alias if the new one is rejected
+			current := tc.newAlias
+			if tc.err != nil {
+				current = "google"
+			}
+
+			_, err = env.storage.GetURL(context.Background(), "", current)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	env := newTestEnv()
+	env.cfg.Janitor.Retention = 24 * time.Hour
+
+	ctx := context.Background()
+	for a, expiredFor := range map[string]time.Duration{"recent": time.Hour, "old": 48 * time.Hour} {
+		expiresAt := time.Now().Add(-expiredFor)
+		_, err := env.storage.SaveURL(ctx, "https://google.com", a, 1, storage.URLOptions{ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, runPurge(ctx, env, nil))
+	require.Equal(t, "deleted 1 expired urls\n", env.out.(*bytes.Buffer).String())
+
+	// urls expired within retention still answer as expired, not missing
+	_, err := env.storage.GetURL(ctx, "", "recent")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+
+	_, err = env.storage.GetURL(ctx, "", "old")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
@@ -0,0 +1,111 @@
+// Command url-shortener-admin manages links in the storage configured for
+// url-shortener without going through the HTTP API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"url-shortener/internal/config"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/backend"
+)
+
+const usage = `usage: url-shortener-admin <command> [flags] [args]
+
+commands:
+  create [-alias alias] [-user id] [-ttl duration] [-strategy name] [-length n] [-redirect status]
+         [-password password] [-max-clicks n] [-title title] [-preview] [-domain domain] url
+  get [-domain domain] alias
+  delete [-domain domain] alias
+  list [-user id] [-limit n]
+  rename [-domain domain] alias new-alias
+  purge
+  stats [-interval hour|day] [-from time] [-to time] [-domain domain] alias
+
+Config is read from CONFIG_PATH like url-shortener does.`
+
+// errUsage is returned by commands called with wrong arguments.
+var errUsage = errors.New("invalid arguments")
+
+// command runs with storage and arguments following its name,
+// and prints results to out.
+type command func(ctx context.Context, env *env, args []string) error
+
+// env is what commands operate on.
+type env struct {
+	cfg     *config.Config
+	storage storage.Storage
+	out     io.Writer
+}
+
+var commands = map[string]command{
+	"create": runCreate,
+	"get":    runGet,
+	"delete": runDelete,
+	"list":   runList,
+	"rename": runRename,
+	"purge":  runPurge,
+	"stats":  runStats,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := config.MustLoad()
+
+	if cfg.Storage.Driver == backend.DriverMemory {
+		fmt.Fprintln(os.Stderr, "memory storage is not shared with the server, nothing to manage")
+		os.Exit(1)
+	}
+
+	s, err := backend.New(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to init storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	err = cmd(ctx, &env{cfg: cfg, storage: s, out: os.Stdout}, os.Args[2:])
+
+	stop()
+	_ = s.Close()
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, describe(err))
+		os.Exit(1)
+	}
+}
+
+// describe returns message for error returned by command.
+// Storage errors are mapped like the HTTP API maps them.
+func describe(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrURLNotFound):
+		return "error: url not found"
+	case errors.Is(err, storage.ErrURLExists):
+		return "error: alias already exists"
+	default:
+		return "error: " + err.Error()
+	}
+}
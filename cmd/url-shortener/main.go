@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
@@ -10,21 +9,56 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
+	"url-shortener/internal/clicks"
 	ssogrpc "url-shortener/internal/clients/sso/grpc"
 	"url-shortener/internal/config"
+	"url-shortener/internal/http-server/handlers/apikeys"
 	deleteHanlder "url-shortener/internal/http-server/handlers/delete"
+	"url-shortener/internal/http-server/handlers/health"
 	"url-shortener/internal/http-server/handlers/login"
+	"url-shortener/internal/http-server/handlers/qr"
 	"url-shortener/internal/http-server/handlers/redirect"
 	"url-shortener/internal/http-server/handlers/register"
+	"url-shortener/internal/http-server/handlers/url/list"
 	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/handlers/url/stats"
+	"url-shortener/internal/http-server/handlers/url/transfer"
+	"url-shortener/internal/http-server/handlers/url/update"
 	"url-shortener/internal/http-server/middleware/authenticator"
 	mwLogger "url-shortener/internal/http-server/middleware/logger"
+	mwMetrics "url-shortener/internal/http-server/middleware/metrics"
+	mwRateLimit "url-shortener/internal/http-server/middleware/ratelimit"
+	mwTracing "url-shortener/internal/http-server/middleware/tracing"
+	"url-shortener/internal/janitor"
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/domain"
 	"url-shortener/internal/lib/logger/handlers/slogpretty"
 	"url-shortener/internal/lib/logger/sl"
-	"url-shortener/internal/storage/sqlite"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage/backend"
+	"url-shortener/internal/storage/instrumented"
 )
 
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+	metricsPath = "/metrics"
+	// readinessTimeout bounds pinging of dependencies by readiness probe.
+	readinessTimeout = 2 * time.Second
+)
+
+// subcommands run instead of the server if named by the first argument.
+var subcommands = map[string]func(log *slog.Logger, cfg *config.Config, args []string) error{
+	"migrate": runMigrate,
+	"export":  runExport,
+	"import":  runImport,
+}
+
 const (
 	envLocal = "local"
 	envDev   = "dev"
@@ -33,11 +67,28 @@ const (
 
 func main() {
 	cfg := config.MustLoad()
-	fmt.Println(cfg)
 
 	log := setupLogger(cfg.Env)
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd(log, cfg, os.Args[2:]); err != nil {
+				log.Error("failed to run "+os.Args[1], sl.Err(err))
+				os.Exit(1)
+			}
+
+			return
+		}
+	}
+
 	log.Info("starting url-shortener", slog.String("env", cfg.Env))
 
+	traceExporter, err := tracing.NewExporter(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.Endpoint)
+	if err != nil {
+		log.Error("failed to init trace exporter", sl.Err(err))
+		os.Exit(1)
+	}
+	tracerProvider := tracing.Setup(traceExporter, cfg.Tracing.SampleRatio)
+
 	ssoClient, err := ssogrpc.New(
 		context.Background(),
 		log,
@@ -53,11 +104,34 @@ func main() {
 
 	log.Debug("debug logging enabled")
 
-	storage, err := sqlite.New(cfg.StoragePath)
+	storage, err := backend.New(cfg)
 	if err != nil {
 		log.Error("failed to init storage", sl.Err(err))
 		os.Exit(1)
 	}
+	storage = instrumented.New(storage)
+
+	clickRecorder := clicks.New(
+		log,
+		storage,
+		cfg.AppSecret,
+		cfg.Clicks.BufferSize,
+		cfg.Clicks.BatchSize,
+		cfg.Clicks.FlushInterval,
+	)
+	clickRecorder.Start()
+
+	urlJanitor := janitor.New(log, storage, cfg.Janitor.Interval, cfg.Janitor.Retention)
+	urlJanitor.Start()
+
+	aliasGenerators := alias.NewStrategies(storage, cfg.Alias.Salt)
+	if _, ok := aliasGenerators[cfg.Alias.Strategy]; !ok {
+		log.Error("unknown alias strategy", slog.String("strategy", cfg.Alias.Strategy))
+		os.Exit(1)
+	}
+
+	// requests on other hosts are served from the primary domain
+	domains := domain.NewAllowlist(cfg.Domains)
 
 	jwtAuth := jwtauth.New(
 		"HS256",
@@ -70,24 +144,68 @@ func main() {
 
 	// middleware
 	r.Use(middleware.RequestID)
-	r.Use(mwLogger.New(log))
+	r.Use(mwTracing.New())
+	r.Use(mwLogger.New(log, healthzPath, readyzPath, metricsPath))
+	r.Use(mwMetrics.New())
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.URLFormat)
 
+	// Probes and metrics
+	r.Get(healthzPath, health.NewLiveness())
+	r.Get(readyzPath, health.NewReadiness(log, readinessTimeout, map[string]health.Pinger{
+		"storage": storage,
+		"sso":     ssoClient,
+	}))
+	r.Handle(metricsPath, metrics.Handler())
+
 	// Protected routes
 	r.Group(func(r chi.Router) {
 		r.Use(jwtauth.Verifier(jwtAuth))
+		// machine clients authenticate with api keys instead of user tokens
+		r.Use(authenticator.APIKey(log, storage))
 		r.Use(authenticator.Authenticator(log, jwtAuth))
 
-		r.Post("/url", save.New(log, storage))
-		r.Delete("/{alias}", deleteHanlder.New(log, storage, ssoClient))
+		readURLs := authenticator.RequireScope(log, apikey.ScopeURLsRead)
+		writeURLs := authenticator.RequireScope(log, apikey.ScopeURLsWrite)
+		// api keys can't manage api keys nor act as admins
+		denyKeys := authenticator.DenyAPIKey(log)
+
+		aliasOpts := save.AliasOptions{
+			Generators: aliasGenerators,
+			Strategy:   cfg.Alias.Strategy,
+			Length:     cfg.Alias.Length,
+		}
+		saveLimit := rateLimit(log, cfg.RateLimit.Save, mwRateLimit.ByUserID)
+		// a batch saves up to save.MaxBatchSize urls, so it has its own bucket
+		batchLimit := rateLimit(log, cfg.RateLimit.Batch, mwRateLimit.ByUserID)
+
+		r.With(writeURLs, saveLimit).Post("/url", save.New(log, storage, aliasOpts, domains))
+		r.With(writeURLs, batchLimit).Post("/url/batch", save.NewBatch(log, storage, aliasOpts, domains))
+		r.With(readURLs).Get("/url", list.New(log, storage))
+		r.With(denyKeys).Get("/admin/urls/export", transfer.NewExport(log, storage, ssoClient))
+		r.With(denyKeys).Post("/admin/urls/import", transfer.NewImport(log, storage, ssoClient, domains))
+		r.With(readURLs).Get("/url/{alias}/stats", stats.New(log, storage, ssoClient))
+		r.With(writeURLs).Patch("/{alias}", update.New(log, storage, ssoClient))
+		r.With(writeURLs).Delete("/{alias}", deleteHanlder.New(log, storage, ssoClient))
+		r.With(denyKeys).Post("/apikeys", apikeys.NewCreate(log, storage))
+		r.With(denyKeys).Get("/apikeys", apikeys.NewList(log, storage))
+		r.With(denyKeys).Delete("/apikeys/{id}", apikeys.NewRevoke(log, storage))
 	})
 
 	// Public routes
 	r.Group(func(r chi.Router) {
-		r.Post("/register", register.New(log, ssoClient))
-		r.Post("/login", login.New(log, ssoClient))
-		r.Get("/{alias}", redirect.New(log, storage))
+		r.With(rateLimit(log, cfg.RateLimit.Register, mwRateLimit.ByIP)).Post("/register", register.New(log, ssoClient))
+		r.With(rateLimit(log, cfg.RateLimit.Login, mwRateLimit.ByIP)).Post("/login", login.New(log, ssoClient))
+		r.With(rateLimit(log, cfg.RateLimit.Redirect, mwRateLimit.ByIP)).Get("/{alias}", redirect.New(log, storage, storage, clickRecorder, redirect.Options{
+			DefaultType:     cfg.Redirect.DefaultType,
+			PermanentMaxAge: cfg.Redirect.PermanentMaxAge,
+			Domains:         domains,
+		}))
+		// guessing passwords of one url doesn't lock others out of it
+		r.With(rateLimit(log, cfg.RateLimit.Password, mwRateLimit.ByIPAndURL(domains))).
+			Post("/{alias}", redirect.NewUnlock(log, storage, storage, clickRecorder, domains))
+		r.With(rateLimit(log, cfg.RateLimit.Redirect, mwRateLimit.ByIP)).
+			Get("/{alias}/qr", qr.New(log, storage, cfg.HTTPServer.PublicURL, domains))
 	})
 
 	log.Info("starting server", slog.String("address", cfg.Address))
@@ -100,11 +218,61 @@ func main() {
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 
-	if err = srv.ListenAndServe(); err != nil {
-		log.Error("failed to start server")
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case sig := <-stop:
+		log.Info("stopping server", slog.String("signal", sig.String()))
+	case err = <-serverErr:
+		log.Error("failed to start server", sl.Err(err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+
+	// in-flight requests are drained first, they may still record clicks
+	if err = srv.Shutdown(ctx); err != nil {
+		log.Error("failed to drain requests", sl.Err(err))
+	}
+
+	if err = urlJanitor.Stop(ctx); err != nil {
+		log.Error("failed to stop janitor", sl.Err(err))
+	}
+
+	if err = clickRecorder.Stop(ctx); err != nil {
+		log.Error("failed to flush clicks", sl.Err(err))
+	}
+
+	if err = storage.Close(); err != nil {
+		log.Error("failed to close storage", sl.Err(err))
+	}
+
+	if err = ssoClient.Close(); err != nil {
+		log.Error("failed to close sso client", sl.Err(err))
+	}
+
+	if err = tracerProvider.Shutdown(ctx); err != nil {
+		log.Error("failed to flush traces", sl.Err(err))
+	}
+
+	log.Info("server stopped")
+}
+
+// rateLimit returns middleware enforcing limit per client key,
+// or middleware passing all requests if limit is disabled.
+func rateLimit(log *slog.Logger, limit config.Limit, key mwRateLimit.KeyFunc) func(http.Handler) http.Handler {
+	var limiter *mwRateLimit.Limiter
+	if limit.Requests > 0 {
+		limiter = mwRateLimit.NewLimiter(limit.Requests, limit.Period, limit.Burst)
 	}
 
-	log.Error("server stopped")
+	return mwRateLimit.New(log, limiter, key)
 }
 
 func setupLogger(env string) *slog.Logger {
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"url-shortener/internal/config"
+	"url-shortener/internal/storage/backend"
+	"url-shortener/internal/storage/migrator"
+	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/sqlite"
+)
+
+const migrateUsage = "usage: url-shortener migrate up | down [steps] | version"
+
+// schemaStorage is storage with versioned schema.
+type schemaStorage interface {
+	Migrator() (*migrator.Migrator, error)
+	Close() error
+}
+
+// runMigrate runs migrate subcommand with given arguments.
+func runMigrate(log *slog.Logger, cfg *config.Config, args []string) error {
+	const op = "main.runMigrate"
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	s, err := openSchemaStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = s.Close() }()
+
+	m, err := s.Migrator()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("migrations applied", slog.Int("count", applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		rolledBack, err := m.Down(steps)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("migrations rolled back", slog.Int("count", rolledBack))
+	case "version":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("schema version", slog.Int("current", version), slog.Int("latest", m.Latest()))
+
+	return nil
+}
+
+func openSchemaStorage(cfg *config.Config) (schemaStorage, error) {
+	switch cfg.Storage.Driver {
+	case backend.DriverSQLite:
+		return sqlite.Open(cfg.StoragePath)
+	case backend.DriverPostgres:
+		return postgres.Open(cfg.Storage.DSN)
+	default:
+		return nil, fmt.Errorf("storage driver %q has no schema to migrate", cfg.Storage.Driver)
+	}
+}
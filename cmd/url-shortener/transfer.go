@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/lib/backup"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/storage/backend"
+)
+
+const (
+	exportUsage = "usage: url-shortener export [-format csv|ndjson] [-user id] file"
+	importUsage = "usage: url-shortener import [-format csv|ndjson] [-on-conflict skip|overwrite|fail] [-user id] file"
+)
+
+// runExport runs export subcommand writing urls to a file.
+// Stdout is not used as it's shared with logs.
+func runExport(log *slog.Logger, cfg *config.Config, args []string) error {
+	const op = "main.runExport"
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", backup.FormatCSV, "file format: csv or ndjson")
+	userID := fs.Int64("user", 0, "export urls of this user only")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errors.New(exportUsage)
+	}
+
+	s, err := backend.New(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = s.Close() }()
+
+	f, err := os.Create(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var filter *int64
+	if *userID != 0 {
+		filter = userID
+	}
+
+	written, err := backup.Export(context.Background(), s, filter, *format, f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("urls exported", slog.Int("count", written))
+
+	return nil
+}
+
+// runImport runs import subcommand saving urls from a file.
+func runImport(log *slog.Logger, cfg *config.Config, args []string) error {
+	const op = "main.runImport"
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", backup.FormatCSV, "file format: csv or ndjson")
+	onConflict := fs.String("on-conflict", string(backup.DefaultConflictPolicy), "skip, overwrite or fail on taken aliases")
+	userID := fs.Int64("user", 0, "owner of urls that have none in the file")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	policy, err := backup.ParseConflictPolicy(*onConflict)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	urls, err := backup.Decode(f, *format, *userID, domain.NewAllowlist(cfg.Domains), time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s, err := backend.New(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = s.Close() }()
+
+	stats, err := s.ImportURLs(context.Background(), urls, policy)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("urls imported",
+		slog.Int("created", stats.Created),
+		slog.Int("updated", stats.Updated),
+		slog.Int("skipped", stats.Skipped),
+	)
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package clicks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+)
+
+type ClickSaver interface {
+	SaveClicks(ctx context.Context, clicks []storage.Click) error
+}
+
+// Recorder collects clicks in memory and saves them in batches
+// from a background goroutine, so redirects don't wait for storage.
+type Recorder struct {
+	log           *slog.Logger
+	clickSaver    ClickSaver
+	ipHashKey     []byte
+	batchSize     int
+	flushInterval time.Duration
+
+	clicks chan storage.Click
+	quit   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func New(
+	log *slog.Logger,
+	clickSaver ClickSaver,
+	ipHashKey string,
+	bufferSize int,
+	batchSize int,
+	flushInterval time.Duration,
+) *Recorder {
+	return &Recorder{
+		log:           log.With(slog.String("component", "clicks/recorder")),
+		clickSaver:    clickSaver,
+		ipHashKey:     []byte(ipHashKey),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		clicks:        make(chan storage.Click, bufferSize),
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start runs the background writer. It must be called once.
+func (r *Recorder) Start() {
+	go r.run()
+}
+
+// Record queues a click without blocking. Clicks are dropped
+// when the buffer is full or the recorder is stopped.
+func (r *Recorder) Record(domain, alias, referrer, userAgent, ip string) {
+	click := storage.Click{
+		Domain:    domain,
+		Alias:     alias,
+		ClickedAt: time.Now(),
+		Referrer:  referrer,
+		UserAgent: userAgent,
+		IPHash:    r.hashIP(ip),
+	}
+
+	select {
+	case <-r.quit:
+		r.log.Warn("recorder is stopped, click dropped", slog.String("alias", alias))
+		return
+	default:
+	}
+
+	select {
+	case r.clicks <- click:
+	default:
+		r.log.Warn("click buffer is full, click dropped", slog.String("alias", alias))
+	}
+}
+
+// Stop flushes queued clicks and stops the background writer.
+// It returns ctx error if flushing doesn't finish in time.
+func (r *Recorder) Stop(ctx context.Context) error {
+	r.once.Do(func() { close(r.quit) })
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Click, 0, r.batchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := r.clickSaver.SaveClicks(context.Background(), batch); err != nil {
+			r.log.Error("failed to save clicks", slog.Int("count", len(batch)), sl.Err(err))
+		}
+
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case click := <-r.clicks:
+			batch = append(batch, click)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-r.quit:
+			for {
+				select {
+				case click := <-r.clicks:
+					batch = append(batch, click)
+					if len(batch) >= r.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, r.ipHashKey)
+	mac.Write([]byte(ip))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
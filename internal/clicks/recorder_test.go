@@ -0,0 +1,72 @@
+package clicks_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/clicks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+type clickSaverStub struct {
+	mu      sync.Mutex
+	batches [][]storage.Click
+}
+
+func (s *clickSaverStub) SaveClicks(_ context.Context, clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batches = append(s.batches, append([]storage.Click(nil), clicks...))
+
+	return nil
+}
+
+func (s *clickSaverStub) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for _, b := range s.batches {
+		n += len(b)
+	}
+
+	return n
+}
+
+func TestRecorder_FlushesOnStop(t *testing.T) {
+	saver := &clickSaverStub{}
+
+	// flush interval is long enough to never fire during the test
+	r := clicks.New(slogdiscard.NewDiscardLogger(), saver, "secret", 100, 3, time.Hour)
+	r.Start()
+
+	for i := 0; i < 7; i++ {
+		r.Record("go.example", "alias", "https://referrer.com", "test-agent", "127.0.0.1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, r.Stop(ctx))
+
+	for _, b := range saver.batches {
+		require.LessOrEqual(t, len(b), 3)
+	}
+	require.Equal(t, 7, saver.count())
+
+	click := saver.batches[0][0]
+	require.Equal(t, "go.example", click.Domain)
+	require.Equal(t, "alias", click.Alias)
+	require.Equal(t, "https://referrer.com", click.Referrer)
+	require.Equal(t, "test-agent", click.UserAgent)
+	require.NotEmpty(t, click.IPHash)
+	require.NotContains(t, click.IPHash, "127.0.0.1")
+
+	// clicks after stop are dropped instead of blocking or panicking
+	r.Record("", "alias", "", "", "")
+	require.NoError(t, r.Stop(ctx))
+	require.Equal(t, 7, saver.count())
+}
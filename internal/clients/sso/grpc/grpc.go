@@ -6,14 +6,19 @@ import (
 	grpclog "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	grpcretry "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/retry"
 	ssov1 "github.com/pingvincible/protos/gen/go/sso"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"log/slog"
 	"time"
+	"url-shortener/internal/lib/metrics"
 )
 
 type Client struct {
+	conn  *grpc.ClientConn
 	api   ssov1.AuthClient
 	appID int32
 	log   *slog.Logger
@@ -40,7 +45,9 @@ func New(
 	}
 	cc, err := grpc.NewClient(addr,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 		grpc.WithChainUnaryInterceptor(
+			MetricsInterceptor(),
 			grpclog.UnaryClientInterceptor(InterceptorLogger(log), logOpts...),
 			grpcretry.UnaryClientInterceptor(retryOpts...),
 		),
@@ -51,11 +58,45 @@ func New(
 	}
 
 	return &Client{
+		conn:  cc,
 		api:   ssov1.NewAuthClient(cc),
 		appID: appID,
 	}, nil
 }
 
+// Ping checks that connection to sso service is ready. Idle connection is
+// asked to connect, and Ping waits for it until ctx is done.
+func (c *Client) Ping(ctx context.Context) error {
+	const op = "grpc.Ping"
+
+	for {
+		state := c.conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Idle:
+			c.conn.Connect()
+		case connectivity.Shutdown:
+			return fmt.Errorf("%s: connection is shut down", op)
+		}
+
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("%s: connection is %s: %w", op, state, ctx.Err())
+		}
+	}
+}
+
+// Close closes connection to sso service.
+func (c *Client) Close() error {
+	const op = "grpc.Close"
+
+	if err := c.conn.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
 func (c *Client) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "grpc.IsAdmin"
 
@@ -105,6 +146,28 @@ func (c *Client) Login(ctx context.Context, email string, password string) (stri
 // This code is simple enough to be copied and not imported.
 func InterceptorLogger(l *slog.Logger) grpclog.Logger {
 	return grpclog.LoggerFunc(func(ctx context.Context, lvl grpclog.Level, msg string, fields ...any) {
-		l.Log(ctx, slog.Level(lvl), msg, fields)
+		l.Log(ctx, slog.Level(lvl), msg, fields...)
 	})
 }
+
+// MetricsInterceptor counts calls by method and status code and observes
+// their duration. Being first in the chain, it sees a call with all retries.
+func MetricsInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		metrics.SSORequests.WithLabelValues(method, status.Code(err).String()).Inc()
+		metrics.SSORequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
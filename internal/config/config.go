@@ -8,12 +8,29 @@ import (
 )
 
 type Config struct {
-	Env         string `yaml:"env" env-default:"local"`
-	StoragePath string `yaml:"storage_path" env-required:"true"`
+	Env         string        `yaml:"env" env-default:"local"`
+	StoragePath string        `yaml:"storage_path"`
+	Storage     StorageConfig `yaml:"storage"`
 	HTTPServer  `yaml:"http_server"`
-	Clients     ClientsConfig `yaml:"clients"`
-	AppSecret   string        `yaml:"app_secret" env-required:"true" env:"APP_SECRET"`
-	AppId       int32         `yaml:"app_id" env-required:"true" env:"APP_ID"`
+	Clients     ClientsConfig  `yaml:"clients"`
+	Clicks      ClicksConfig   `yaml:"clicks"`
+	Janitor     JanitorConfig  `yaml:"janitor"`
+	Alias       AliasConfig    `yaml:"alias"`
+	Tracing     TracingConfig  `yaml:"tracing"`
+	RateLimit   LimitsConfig   `yaml:"rate_limit"`
+	Redirect    RedirectConfig `yaml:"redirect"`
+	AppSecret   string         `yaml:"app_secret" env-required:"true" env:"APP_SECRET"`
+	AppId       int32          `yaml:"app_id" env-required:"true" env:"APP_ID"`
+	// Domains are short domains urls may be saved on besides the primary
+	// one. Each has its own namespace of aliases.
+	Domains []string `yaml:"domains"`
+}
+
+// StorageConfig selects storage backend: sqlite, postgres or memory.
+// StoragePath is required by sqlite, DSN by postgres.
+type StorageConfig struct {
+	Driver string `yaml:"driver" env-default:"sqlite" env:"STORAGE_DRIVER"`
+	DSN    string `yaml:"dsn" env:"STORAGE_DSN"`
 }
 
 type HTTPServer struct {
@@ -22,6 +39,12 @@ type HTTPServer struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 	User        string        `yaml:"user" env-required:"true"`
 	Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	// PublicURL is scheme and host short urls start with, e.g. in QR codes.
+	// It's taken from requests if empty.
+	PublicURL string `yaml:"public_url" env:"HTTP_SERVER_PUBLIC_URL"`
+
+	// ShutdownTimeout bounds draining of requests and background workers on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
 }
 
 type Client struct {
@@ -31,6 +54,66 @@ type Client struct {
 	//Insecure     bool          `yaml:"insecure"`
 }
 
+// ClicksConfig configures buffered writing of click events.
+type ClicksConfig struct {
+	BufferSize    int           `yaml:"buffer_size" env-default:"1024"`
+	BatchSize     int           `yaml:"batch_size" env-default:"100"`
+	FlushInterval time.Duration `yaml:"flush_interval" env-default:"1s"`
+}
+
+// JanitorConfig configures purging of expired urls. Zero Interval disables
+// purging. Expired and used up urls answer 410 Gone for Retention and
+// 404 Not Found once purged.
+type JanitorConfig struct {
+	Interval  time.Duration `yaml:"interval" env-default:"1h"`
+	Retention time.Duration `yaml:"retention" env-default:"168h"`
+}
+
+// AliasConfig configures generation of aliases that are not given by user.
+// Strategy is one of random, sequential, hashids or words.
+type AliasConfig struct {
+	Strategy string `yaml:"strategy" env-default:"random"`
+	Length   int    `yaml:"length" env-default:"6"`
+	// Salt makes hashids aliases unique to the deployment.
+	Salt string `yaml:"salt" env:"ALIAS_SALT"`
+}
+
+// TracingConfig configures export of traces. Exporter is one of none,
+// stdout or otlp, Endpoint is the address of otlp grpc collector.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env-default:"none" env:"TRACING_EXPORTER"`
+	Endpoint    string  `yaml:"endpoint" env-default:"localhost:4317" env:"TRACING_ENDPOINT"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+// LimitsConfig configures per-client limits of requests. Saving urls is
+// limited per user, batches of urls separately from single ones, password
+// attempts per url and client IP, other routes per client IP.
+type LimitsConfig struct {
+	Save     Limit `yaml:"save"`
+	Batch    Limit `yaml:"batch"`
+	Login    Limit `yaml:"login"`
+	Register Limit `yaml:"register"`
+	Redirect Limit `yaml:"redirect"`
+	Password Limit `yaml:"password"`
+}
+
+// Limit allows Requests per Period with bursts of up to Burst requests.
+// Zero Requests disables the limit.
+type Limit struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period" env-default:"1m"`
+	Burst    int           `yaml:"burst"`
+}
+
+// RedirectConfig configures redirects. DefaultType is HTTP status of
+// redirects of urls saved without one: 301, 302, 307 or 308.
+type RedirectConfig struct {
+	DefaultType int `yaml:"default_type" env-default:"302"`
+	// PermanentMaxAge is how long browsers may cache 301 and 308 redirects.
+	PermanentMaxAge time.Duration `yaml:"permanent_max_age" env-default:"24h"`
+}
+
 type ClientsConfig struct {
 	SSO Client `yaml:"sso"`
 }
@@ -51,5 +134,38 @@ func MustLoad() *Config {
 		log.Fatalf("cannot read config: %s", err)
 	}
 
+	switch cfg.Storage.Driver {
+	case "sqlite":
+		if cfg.StoragePath == "" {
+			log.Fatal("storage_path is required for sqlite storage")
+		}
+	case "postgres":
+		if cfg.Storage.DSN == "" {
+			log.Fatal("storage.dsn is required for postgres storage")
+		}
+	}
+
+	if cfg.Alias.Length < 1 || cfg.Alias.Length > 16 {
+		log.Fatal("alias.length must be between 1 and 16")
+	}
+
+	if cfg.Clicks.BufferSize < 1 || cfg.Clicks.BatchSize < 1 {
+		log.Fatal("clicks.buffer_size and clicks.batch_size must be positive")
+	}
+
+	if cfg.Clicks.FlushInterval <= 0 {
+		log.Fatal("clicks.flush_interval must be positive")
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		log.Fatal("tracing.sample_ratio must be between 0 and 1")
+	}
+
+	switch cfg.Redirect.DefaultType {
+	case 301, 302, 307, 308:
+	default:
+		log.Fatal("redirect.default_type must be one of 301, 302, 307 or 308")
+	}
+
 	return &cfg
 }
@@ -0,0 +1,279 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+)
+
+type Request struct {
+	// Name describes where the key is used, e.g. "ci".
+	Name   string   `json:"name,omitempty" validate:"max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=urls:read urls:write"`
+}
+
+// Key describes api key without the key itself.
+type Key struct {
+	ID int64 `json:"id"`
+	// Prefix is the start of the key to tell it apart from other keys.
+	Prefix    string    `json:"prefix"`
+	Name      string    `json:"name,omitempty"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateResponse struct {
+	resp.Response
+	Key
+	// APIKey is shown only once, it can't be recovered later.
+	APIKey string `json:"api_key"`
+}
+
+type ListResponse struct {
+	resp.Response
+	Keys []Key `json:"keys"`
+}
+
+// APIKeySaver is an interface for saving api keys.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=APIKeySaver
+type APIKeySaver interface {
+	SaveAPIKey(ctx context.Context, key storage.APIKey) (int64, error)
+}
+
+// APIKeyLister is an interface for listing api keys of user.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=APIKeyLister
+type APIKeyLister interface {
+	ListAPIKeys(ctx context.Context, userID int64) ([]storage.APIKey, error)
+}
+
+// APIKeyRevoker is an interface for deleting api keys of user.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=APIKeyRevoker
+type APIKeyRevoker interface {
+	DeleteAPIKey(ctx context.Context, userID, id int64) error
+}
+
+// NewCreate returns handler creating api key of the authenticated user.
+// The key acts on behalf of the user within its scopes.
+func NewCreate(log *slog.Logger, keySaver APIKeySaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.NewCreate"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		userId, ok := authenticator.UserIdFromContext(r.Context())
+		if !ok {
+			log.Info("failed to get userId from context")
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		key, prefix, hash, err := apikey.Generate()
+		if err != nil {
+			log.Error("failed to generate api key", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		scopes := slices.Clone(req.Scopes)
+		slices.Sort(scopes)
+		scopes = slices.Compact(scopes)
+
+		now := time.Now().UTC().Truncate(time.Second)
+
+		id, err := keySaver.SaveAPIKey(ctx, storage.APIKey{
+			UserID:    userId,
+			Name:      req.Name,
+			Prefix:    prefix,
+			Hash:      hash,
+			Scopes:    scopes,
+			CreatedAt: now,
+		})
+		if err != nil {
+			log.Error("failed to save api key", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("api key created", slog.Int64("id", id), slog.String("prefix", prefix))
+
+		render.JSON(w, r, CreateResponse{
+			Response: resp.OK(),
+			Key: Key{
+				ID:        id,
+				Prefix:    prefix,
+				Name:      req.Name,
+				Scopes:    scopes,
+				CreatedAt: now,
+			},
+			APIKey: key,
+		})
+	}
+}
+
+// NewList returns handler listing api keys of the authenticated user.
+func NewList(log *slog.Logger, keyLister APIKeyLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.NewList"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		userId, ok := authenticator.UserIdFromContext(r.Context())
+		if !ok {
+			log.Info("failed to get userId from context")
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		keys, err := keyLister.ListAPIKeys(ctx, userId)
+		if err != nil {
+			log.Error("failed to list api keys", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		res := make([]Key, 0, len(keys))
+		for _, k := range keys {
+			res = append(res, Key{
+				ID:        k.ID,
+				Prefix:    k.Prefix,
+				Name:      k.Name,
+				Scopes:    k.Scopes,
+				CreatedAt: k.CreatedAt,
+			})
+		}
+
+		render.JSON(w, r, ListResponse{
+			Response: resp.OK(),
+			Keys:     res,
+		})
+	}
+}
+
+// NewRevoke returns handler deleting api key with given id. Users can
+// revoke only their own keys, keys of others are not found.
+func NewRevoke(log *slog.Logger, keyRevoker APIKeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.NewRevoke"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		userId, ok := authenticator.UserIdFromContext(r.Context())
+		if !ok {
+			log.Info("failed to get userId from context")
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			log.Info("invalid id", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		err = keyRevoker.DeleteAPIKey(ctx, userId, id)
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Info("api key not found", slog.Int64("id", id))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to revoke api key", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("api key revoked", slog.Int64("id", id))
+
+		render.Status(r, http.StatusNoContent)
+		render.JSON(w, r, struct{}{})
+	}
+}
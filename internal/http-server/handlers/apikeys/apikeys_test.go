@@ -0,0 +1,192 @@
+package apikeys_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/apikeys"
+	"url-shortener/internal/http-server/handlers/apikeys/mocks"
+	mocks2 "url-shortener/internal/http-server/middleware/authenticator/mocks"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestCreateHandler(t *testing.T) {
+	const userId = int64(42)
+
+	cases := []struct {
+		name      string
+		body      string
+		scopes    []string
+		mockError error
+		respCode  int
+		respError string
+	}{
+		{
+			name:     "Success",
+			body:     `{"name": "ci", "scopes": ["urls:write", "urls:read", "urls:write"]}`,
+			scopes:   []string{"urls:read", "urls:write"},
+			respCode: http.StatusOK,
+		},
+		{
+			name:      "No scopes",
+			body:      `{"name": "ci", "scopes": []}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Scopes is not valid",
+		},
+		{
+			name:      "Unknown scope",
+			body:      `{"scopes": ["admin"]}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Scopes[0] must be one of urls:read urls:write",
+		},
+		{
+			name:      "SaveAPIKey Error",
+			body:      `{"scopes": ["urls:read"]}`,
+			scopes:    []string{"urls:read"},
+			mockError: errors.New("unexpected error"),
+			respCode:  http.StatusInternalServerError,
+			respError: "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var saved storage.APIKey
+			keySaverMock := mocks.NewAPIKeySaver(t)
+			if tc.scopes != nil {
+				keySaverMock.On("SaveAPIKey", mock.Anything, mock.MatchedBy(func(key storage.APIKey) bool {
+					return key.UserID == userId
+				})).
+					Run(func(args mock.Arguments) { saved = args.Get(1).(storage.APIKey) }).
+					Return(int64(1), tc.mockError).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Post("/apikeys", apikeys.NewCreate(slogdiscard.NewDiscardLogger(), keySaverMock))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/apikeys", bytes.NewReader([]byte(tc.body))))
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			var res apikeys.CreateResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			require.Equal(t, tc.respError, res.Error)
+
+			if tc.respError != "" {
+				return
+			}
+
+			// only hash of the returned key is stored
+			require.True(t, apikey.IsKey(res.APIKey))
+			require.Equal(t, apikey.Hash(res.APIKey), saved.Hash)
+			require.NotContains(t, saved.Hash, res.APIKey)
+			require.Equal(t, saved.Prefix, res.Prefix)
+			require.Equal(t, tc.scopes, saved.Scopes)
+			require.Equal(t, tc.scopes, res.Scopes)
+			require.Equal(t, int64(1), res.ID)
+		})
+	}
+}
+
+func TestListHandler(t *testing.T) {
+	const userId = int64(42)
+
+	createdAt := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	keyListerMock := mocks.NewAPIKeyLister(t)
+	keyListerMock.On("ListAPIKeys", mock.Anything, userId).
+		Return([]storage.APIKey{
+			{ID: 3, UserID: userId, Name: "ci", Prefix: "us_abcdefgh", Hash: "secret", Scopes: []string{"urls:write"}, CreatedAt: createdAt},
+		}, nil).
+		Once()
+
+	r := chi.NewRouter()
+	r.Use(mocks2.UserIdAdder(userId))
+	r.Get("/apikeys", apikeys.NewList(slogdiscard.NewDiscardLogger(), keyListerMock))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/apikeys", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotContains(t, rr.Body.String(), "secret")
+
+	var res apikeys.ListResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.Equal(t, []apikeys.Key{
+		{ID: 3, Prefix: "us_abcdefgh", Name: "ci", Scopes: []string{"urls:write"}, CreatedAt: createdAt},
+	}, res.Keys)
+}
+
+func TestRevokeHandler(t *testing.T) {
+	const userId = int64(42)
+
+	cases := []struct {
+		name      string
+		id        string
+		mockError error
+		respCode  int
+	}{
+		{
+			name:     "Success",
+			id:       "3",
+			respCode: http.StatusNoContent,
+		},
+		{
+			name:      "Not found",
+			id:        "3",
+			mockError: storage.ErrAPIKeyNotFound,
+			respCode:  http.StatusNotFound,
+		},
+		{
+			name:     "Invalid id",
+			id:       "abc",
+			respCode: http.StatusBadRequest,
+		},
+		{
+			name:      "DeleteAPIKey Error",
+			id:        "3",
+			mockError: errors.New("unexpected error"),
+			respCode:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyRevokerMock := mocks.NewAPIKeyRevoker(t)
+			if tc.respCode != http.StatusBadRequest {
+				keyRevokerMock.On("DeleteAPIKey", mock.Anything, userId, int64(3)).
+					Return(tc.mockError).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Delete("/apikeys/{id}", apikeys.NewRevoke(slogdiscard.NewDiscardLogger(), keyRevokerMock))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/apikeys/"+tc.id, nil))
+
+			require.Equal(t, tc.respCode, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// APIKeyLister is an autogenerated mock type for the APIKeyLister type
+type APIKeyLister struct {
+	mock.Mock
+}
+
+// ListAPIKeys provides a mock function with given fields: ctx, userID
+func (_m *APIKeyLister) ListAPIKeys(ctx context.Context, userID int64) ([]storage.APIKey, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListAPIKeys")
+	}
+
+	var r0 []storage.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) ([]storage.APIKey, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []storage.APIKey); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.APIKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewAPIKeyLister creates a new instance of APIKeyLister. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAPIKeyLister(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *APIKeyLister {
+	mock := &APIKeyLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
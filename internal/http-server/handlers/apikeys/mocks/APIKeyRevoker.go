@@ -0,0 +1,46 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// APIKeyRevoker is an autogenerated mock type for the APIKeyRevoker type
+type APIKeyRevoker struct {
+	mock.Mock
+}
+
+// DeleteAPIKey provides a mock function with given fields: ctx, userID, id
+func (_m *APIKeyRevoker) DeleteAPIKey(ctx context.Context, userID int64, id int64) error {
+	ret := _m.Called(ctx, userID, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAPIKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) error); ok {
+		r0 = rf(ctx, userID, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewAPIKeyRevoker creates a new instance of APIKeyRevoker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAPIKeyRevoker(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *APIKeyRevoker {
+	mock := &APIKeyRevoker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,57 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// APIKeySaver is an autogenerated mock type for the APIKeySaver type
+type APIKeySaver struct {
+	mock.Mock
+}
+
+// SaveAPIKey provides a mock function with given fields: ctx, key
+func (_m *APIKeySaver) SaveAPIKey(ctx context.Context, key storage.APIKey) (int64, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveAPIKey")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.APIKey) (int64, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, storage.APIKey) int64); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, storage.APIKey) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewAPIKeySaver creates a new instance of APIKeySaver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAPIKeySaver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *APIKeySaver {
+	mock := &APIKeySaver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
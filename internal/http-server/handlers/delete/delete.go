@@ -9,13 +9,21 @@ import (
 	"log/slog"
 	"net/http"
 	"url-shortener/internal/http-server/middleware/authenticator"
+	"url-shortener/internal/lib/access"
 	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
 )
 
+// URLDeleter is an interface for deleting url by domain and alias.
+// GetURLOwner is used to allow users to delete urls they created.
+//
 //go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLDeleter
 type URLDeleter interface {
-	DeleteURL(alias string) error
+	GetURLOwner(ctx context.Context, domain, alias string) (int64, error)
+	DeleteURL(ctx context.Context, domain, alias string) error
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=IsAdminChecker
@@ -27,6 +35,8 @@ var (
 	ErrInvalidUserId = errors.New("invalid user id")
 )
 
+// New returns handler deleting url with given alias. Urls on other domains
+// than the primary one are selected by domain query parameter.
 func New(log *slog.Logger, urlDeleter URLDeleter, isAdminChecker IsAdminChecker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.delete.New"
@@ -36,9 +46,10 @@ func New(log *slog.Logger, urlDeleter URLDeleter, isAdminChecker IsAdminChecker)
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		userIdAny := r.Context().Value(authenticator.UserIdCtxKey)
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
 
-		userId, ok := userIdAny.(int64)
+		userId, ok := authenticator.UserIdFromContext(r.Context())
 		if !ok {
 			log.Info(
 				"failed to get userId from context",
@@ -51,45 +62,50 @@ func New(log *slog.Logger, urlDeleter URLDeleter, isAdminChecker IsAdminChecker)
 			return
 		}
 
-		isAdmin, err := isAdminChecker.IsAdmin(context.Background(), userId)
-
-		if err != nil {
-			log.Info(
-				"failed to check if user isAdmin",
-				sl.Err(err),
-			)
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
 
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("internal error"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
 
 			return
 		}
 
-		if !isAdmin {
-			log.Info("user isn't admin")
+		urlDomain := domain.Normalize(r.URL.Query().Get("domain"))
 
-			render.Status(r, http.StatusForbidden)
-			render.JSON(w, r, resp.Error("you are not admin"))
+		err := access.CanManageURL(ctx, urlDeleter, isAdminChecker, urlDomain, alias, userId)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", "alias", alias)
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
 
 			return
 		}
+		if errors.Is(err, access.ErrForbidden) {
+			log.Info("user is neither owner nor admin", slog.Int64("user_id", userId))
 
-		alias := chi.URLParam(r, "alias")
-		if alias == "" {
-			log.Info("alias is empty")
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, resp.Error("you are not allowed to delete this url"))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+		if err != nil {
+			log.Info("failed to check access to url", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
 
 			return
 		}
 
-		err = urlDeleter.DeleteURL(alias)
+		err = urlDeleter.DeleteURL(ctx, urlDomain, alias)
 		if err != nil {
 			log.Info("failed to delete url", "alias", alias, "error", err)
 
 			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, "internal error")
+			render.JSON(w, r, resp.Error("internal error"))
 
 			return
 		}
@@ -1,9 +1,9 @@
 package delete_test
 
 import (
-	"context"
 	"errors"
 	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
@@ -12,60 +12,115 @@ import (
 	"url-shortener/internal/http-server/handlers/delete/mocks"
 	mocks2 "url-shortener/internal/http-server/middleware/authenticator/mocks"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
 )
 
 func TestDeleteHandler(t *testing.T) {
 	cases := []struct {
 		name                    string
 		alias                   string
-		urlDeleterMockError     error
+		domain                  string
 		userId                  int64
+		viaAPIKey               bool
+		shouldGetOwner          bool
+		ownerId                 int64
+		getOwnerMockError       error
 		shouldCallIsAdmin       bool
 		isAdmin                 bool
 		isAdminCheckerMockError error
+		shouldDelete            bool
+		urlDeleterMockError     error
 		statusCode              int
 	}{
 		{
-			name:              "Success",
+			name:           "Owner deletes own url",
+			alias:          "test_alias",
+			userId:         int64(1),
+			shouldGetOwner: true,
+			ownerId:        int64(1),
+			shouldDelete:   true,
+			statusCode:     http.StatusNoContent,
+		},
+		{
+			name:              "Admin deletes someone else's url",
 			alias:             "test_alias",
-			statusCode:        http.StatusNoContent,
-			shouldCallIsAdmin: true,
 			userId:            int64(1),
+			shouldGetOwner:    true,
+			ownerId:           int64(2),
+			shouldCallIsAdmin: true,
 			isAdmin:           true,
+			shouldDelete:      true,
+			statusCode:        http.StatusNoContent,
 		},
 		{
-			name:                    "Empty alias",
-			alias:                   "",
-			statusCode:              http.StatusNotFound,
-			shouldCallIsAdmin:       false,
-			userId:                  int64(1),
-			isAdmin:                 false,
-			isAdminCheckerMockError: errors.New("mock must not be called"),
+			name:           "Owner deletes url on custom domain",
+			alias:          "test_alias",
+			domain:         "go.example",
+			userId:         int64(1),
+			shouldGetOwner: true,
+			ownerId:        int64(1),
+			shouldDelete:   true,
+			statusCode:     http.StatusNoContent,
 		},
 		{
-			name:              "User is not admin",
+			name:           "Admin's api key deletes someone else's url",
+			alias:          "test_alias",
+			userId:         int64(1),
+			viaAPIKey:      true,
+			shouldGetOwner: true,
+			ownerId:        int64(2),
+			statusCode:     http.StatusForbidden,
+		},
+		{
+			name:       "Empty alias",
+			alias:      "",
+			userId:     int64(1),
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:              "User is neither owner nor admin",
 			alias:             "test_alias",
-			statusCode:        http.StatusForbidden,
-			shouldCallIsAdmin: true,
 			userId:            int64(1),
+			shouldGetOwner:    true,
+			ownerId:           int64(2),
+			shouldCallIsAdmin: true,
 			isAdmin:           false,
+			statusCode:        http.StatusForbidden,
+		},
+		{
+			name:              "URL not found",
+			alias:             "test_alias",
+			userId:            int64(1),
+			shouldGetOwner:    true,
+			getOwnerMockError: storage.ErrURLNotFound,
+			statusCode:        http.StatusNotFound,
+		},
+		{
+			name:              "Error in GetURLOwner method",
+			alias:             "test_alias",
+			userId:            int64(1),
+			shouldGetOwner:    true,
+			getOwnerMockError: errors.New("unexpected error"),
+			statusCode:        http.StatusInternalServerError,
 		},
 		{
 			name:                    "Error in IsAdmin method",
 			alias:                   "test_alias",
-			statusCode:              http.StatusInternalServerError,
-			shouldCallIsAdmin:       true,
 			userId:                  int64(1),
-			isAdmin:                 false,
+			shouldGetOwner:          true,
+			ownerId:                 int64(2),
+			shouldCallIsAdmin:       true,
 			isAdminCheckerMockError: errors.New("unexpected error"),
+			statusCode:              http.StatusInternalServerError,
 		},
 		{
 			name:                "DeleteURL Error",
 			alias:               "test_alias",
-			urlDeleterMockError: errors.New("unexpected error"),
-			shouldCallIsAdmin:   true,
 			userId:              int64(1),
-			isAdmin:             true,
+			shouldGetOwner:      true,
+			ownerId:             int64(1),
+			shouldDelete:        true,
+			urlDeleterMockError: errors.New("unexpected error"),
 			statusCode:          http.StatusBadRequest,
 		},
 	}
@@ -81,23 +136,32 @@ func TestDeleteHandler(t *testing.T) {
 			if tc.shouldCallIsAdmin {
 				isAdminCheckerMock.On(
 					"IsAdmin",
-					context.Background(),
+					mock.Anything,
 					tc.userId,
 				).
 					Return(tc.isAdmin, tc.isAdminCheckerMockError).
 					Once()
 			}
-			urlDeleterMock := mocks.NewURLDeleter(t)
 
-			if tc.isAdmin && tc.isAdminCheckerMockError == nil {
-				urlDeleterMock.On("DeleteURL", tc.alias).
+			urlDeleterMock := mocks.NewURLDeleter(t)
+			if tc.shouldGetOwner {
+				urlDeleterMock.On("GetURLOwner", mock.Anything, tc.domain, tc.alias).
+					Return(tc.ownerId, tc.getOwnerMockError).
+					Once()
+			}
+			if tc.shouldDelete {
+				urlDeleterMock.On("DeleteURL", mock.Anything, tc.domain, tc.alias).
 					Return(tc.urlDeleterMockError).
 					Once()
 			}
 
 			// Creating router and route with handler
 			r := chi.NewRouter()
-			r.Use(mocks2.UserIdAdder(tc.userId))
+			if tc.viaAPIKey {
+				r.Use(mocks2.APIKeyAdder(tc.userId, "urls:write"))
+			} else {
+				r.Use(mocks2.UserIdAdder(tc.userId))
+			}
 			r.Delete(
 				"/{alias}",
 				deleteHandler.New(
@@ -108,7 +172,12 @@ func TestDeleteHandler(t *testing.T) {
 			)
 
 			// Act
-			req, err := http.NewRequest("DELETE", "/"+tc.alias, nil)
+			target := "/" + tc.alias
+			if tc.domain != "" {
+				target += "?domain=" + tc.domain
+			}
+
+			req, err := http.NewRequest("DELETE", target, nil)
 			rr := httptest.NewRecorder()
 			r.ServeHTTP(rr, req)
 
@@ -2,24 +2,28 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 // URLDeleter is an autogenerated mock type for the URLDeleter type
 type URLDeleter struct {
 	mock.Mock
 }
 
-// DeleteURL provides a mock function with given fields: alias
-func (_m *URLDeleter) DeleteURL(alias string) error {
-	ret := _m.Called(alias)
+// DeleteURL provides a mock function with given fields: ctx, domain, alias
+func (_m *URLDeleter) DeleteURL(ctx context.Context, domain string, alias string) error {
+	ret := _m.Called(ctx, domain, alias)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteURL")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, domain, alias)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -27,6 +31,34 @@ func (_m *URLDeleter) DeleteURL(alias string) error {
 	return r0
 }
 
+// GetURLOwner provides a mock function with given fields: ctx, domain, alias
+func (_m *URLDeleter) GetURLOwner(ctx context.Context, domain string, alias string) (int64, error) {
+	ret := _m.Called(ctx, domain, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetURLOwner")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (int64, error)); ok {
+		return rf(ctx, domain, alias)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int64); ok {
+		r0 = rf(ctx, domain, alias)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, domain, alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewURLDeleter creates a new instance of URLDeleter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewURLDeleter(t interface {
@@ -0,0 +1,93 @@
+package health
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+)
+
+type Response struct {
+	resp.Response
+	Checks map[string]Check `json:"checks,omitempty"`
+}
+
+// Check is the state of a single dependency.
+type Check struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=Pinger
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// NewLiveness returns handler reporting that the process is able to serve
+// requests. It doesn't check dependencies: a broken dependency must not
+// get a healthy instance restarted.
+func NewLiveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, Response{Response: resp.OK()})
+	}
+}
+
+// NewReadiness returns handler pinging dependencies concurrently. It responds
+// with 503 unless every dependency answers within timeout.
+func NewReadiness(log *slog.Logger, timeout time.Duration, dependencies map[string]Pinger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.health.NewReadiness"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		var (
+			mu     sync.Mutex
+			wg     sync.WaitGroup
+			checks = make(map[string]Check, len(dependencies))
+			ready  = true
+		)
+
+		for name, dep := range dependencies {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				check := Check{Status: resp.StatusOK}
+				if err := dep.Ping(ctx); err != nil {
+					log.Error("dependency is not ready", slog.String("dependency", name), sl.Err(err))
+
+					check = Check{Status: resp.StatusError, Error: err.Error()}
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				checks[name] = check
+				if check.Status != resp.StatusOK {
+					ready = false
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		res := Response{Response: resp.OK(), Checks: checks}
+		if !ready {
+			res.Response = resp.Error("not ready")
+			render.Status(r, http.StatusServiceUnavailable)
+		}
+
+		render.JSON(w, r, res)
+	}
+}
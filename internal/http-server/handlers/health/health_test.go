@@ -0,0 +1,98 @@
+package health_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/health"
+	"url-shortener/internal/http-server/handlers/health/mocks"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+func TestLivenessHandler(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	health.NewLiveness().ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var res health.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.Equal(t, resp.StatusOK, res.Status)
+}
+
+func TestReadinessHandler(t *testing.T) {
+	cases := []struct {
+		name       string
+		storageErr error
+		ssoErr     error
+		respCode   int
+		checks     map[string]health.Check
+	}{
+		{
+			name:     "Ready",
+			respCode: http.StatusOK,
+			checks: map[string]health.Check{
+				"storage": {Status: resp.StatusOK},
+				"sso":     {Status: resp.StatusOK},
+			},
+		},
+		{
+			name:     "SSO not ready",
+			ssoErr:   errors.New("connection is TRANSIENT_FAILURE"),
+			respCode: http.StatusServiceUnavailable,
+			checks: map[string]health.Check{
+				"storage": {Status: resp.StatusOK},
+				"sso":     {Status: resp.StatusError, Error: "connection is TRANSIENT_FAILURE"},
+			},
+		},
+		{
+			name:       "Storage not ready",
+			storageErr: errors.New("database is locked"),
+			respCode:   http.StatusServiceUnavailable,
+			checks: map[string]health.Check{
+				"storage": {Status: resp.StatusError, Error: "database is locked"},
+				"sso":     {Status: resp.StatusOK},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := mocks.NewPinger(t)
+			storageMock.On("Ping", mock.Anything).Return(tc.storageErr).Once()
+
+			ssoMock := mocks.NewPinger(t)
+			ssoMock.On("Ping", mock.Anything).Return(tc.ssoErr).Once()
+
+			handler := health.NewReadiness(slogdiscard.NewDiscardLogger(), time.Second, map[string]health.Pinger{
+				"storage": storageMock,
+				"sso":     ssoMock,
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			var res health.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			require.Equal(t, tc.checks, res.Checks)
+		})
+	}
+}
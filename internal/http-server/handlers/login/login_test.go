@@ -2,10 +2,10 @@ package login_test
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
@@ -85,7 +85,7 @@ func TestLoginHandler(t *testing.T) {
 
 			if tc.respError == "" || tc.mockError != nil {
 				userLoginerMock.
-					On("Login", context.Background(), tc.email, tc.password).
+					On("Login", mock.Anything, tc.email, tc.password).
 					Return("", tc.mockError).
 					Once()
 			}
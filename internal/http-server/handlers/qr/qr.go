@@ -0,0 +1,228 @@
+package qr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+)
+
+const (
+	defaultSize   = 256
+	minSize       = 64
+	maxSize       = 2048
+	defaultMargin = 4
+	maxMargin     = 16
+)
+
+// URLGetter is an interface for getting url by alias.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLGetter
+type URLGetter interface {
+	GetURL(ctx context.Context, domain, alias string) (storage.URL, error)
+}
+
+// New returns handler of QR code of short url with given alias on domain
+// of the request, resolved by domains like redirects are. Short urls start
+// with baseURL, or with scheme and host of the request if it's empty.
+// Urls on other domains than the primary one keep scheme of baseURL.
+//
+// Query parameters:
+//   - format is png (default) or svg, also set by extension, e.g. /abc/qr.svg
+//   - size is width of the code in pixels, 64 to 2048
+//   - level is error correction level: L, M (default), Q or H
+//   - margin is width of quiet zone in modules, 0 to 16
+func New(log *slog.Logger, urlGetter URLGetter, baseURL string, domains domain.Allowlist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.qr.New"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		opts, err := parseOptions(r)
+		if err != nil {
+			log.Info("invalid options", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		u, err := urlGetter.GetURL(ctx, domains.FromHost(r.Host), alias)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", "alias", alias)
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrURLExpired) || errors.Is(err, storage.ErrURLExhausted) {
+			log.Info("url is gone", "alias", alias, sl.Err(err))
+
+			render.Status(r, http.StatusGone)
+			render.JSON(w, r, resp.Error("url is gone"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get url", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal server error"))
+
+			return
+		}
+
+		shortURL := shortURLBase(r, baseURL, u.Domain) + "/" + alias
+
+		// the code depends only on its content and options,
+		// so it's revalidated without rendering
+		etag := etagOf(shortURL, opts)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, no-cache")
+
+		if matchesETag(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		body, err := encode(shortURL, opts)
+		if err != nil {
+			log.Error("failed to encode qr code", sl.Err(err))
+
+			w.Header().Del("ETag")
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to encode qr code"))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType(opts.format))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body)
+	}
+}
+
+func parseOptions(r *http.Request) (options, error) {
+	q := r.URL.Query()
+
+	opts := options{
+		format: q.Get("format"),
+		level:  strings.ToUpper(q.Get("level")),
+		size:   defaultSize,
+		margin: defaultMargin,
+	}
+	if opts.format == "" {
+		opts.format, _ = r.Context().Value(middleware.URLFormatCtxKey).(string)
+	}
+
+	switch opts.format {
+	case "":
+		opts.format = formatPNG
+	case formatPNG, formatSVG:
+	default:
+		return options{}, errors.New("field format must be one of png svg")
+	}
+
+	switch opts.level {
+	case "":
+		opts.level = "M"
+	case "L", "M", "Q", "H":
+	default:
+		return options{}, errors.New("field level must be one of L M Q H")
+	}
+
+	if v := q.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < minSize || size > maxSize {
+			return options{}, fmt.Errorf("field size must be between %d and %d", minSize, maxSize)
+		}
+		opts.size = size
+	}
+
+	if v := q.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil || margin < 0 || margin > maxMargin {
+			return options{}, fmt.Errorf("field margin must be between 0 and %d", maxMargin)
+		}
+		opts.margin = margin
+	}
+
+	return opts, nil
+}
+
+// shortURLBase returns baseURL without trailing slash, or scheme and host
+// of r if baseURL is empty. Host is replaced by d unless it's the primary
+// domain.
+func shortURLBase(r *http.Request, baseURL, d string) string {
+	if baseURL != "" {
+		if d == "" {
+			return strings.TrimSuffix(baseURL, "/")
+		}
+
+		if parsed, err := url.Parse(baseURL); err == nil && parsed.Scheme != "" {
+			return parsed.Scheme + "://" + d
+		}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if d != "" {
+		return scheme + "://" + d
+	}
+
+	return scheme + "://" + r.Host
+}
+
+func etagOf(shortURL string, opts options) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d|%s", opts.format, opts.level, opts.size, opts.margin, shortURL)))
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matchesETag tells whether If-None-Match header lists etag.
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,200 @@
+package qr_test
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/http-server/handlers/qr"
+	"url-shortener/internal/http-server/handlers/qr/mocks"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestQRHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		path        string
+		mockError   error
+		statusCode  int
+		contentType string
+		respError   string
+	}{
+		{
+			name:        "PNG by default",
+			path:        "/test_alias/qr",
+			statusCode:  http.StatusOK,
+			contentType: "image/png",
+		},
+		{
+			name:        "SVG",
+			path:        "/test_alias/qr?format=svg&level=h&margin=0",
+			statusCode:  http.StatusOK,
+			contentType: "image/svg+xml",
+		},
+		{
+			name:        "SVG by extension",
+			path:        "/test_alias/qr.svg",
+			statusCode:  http.StatusOK,
+			contentType: "image/svg+xml",
+		},
+		{
+			name:       "Too large",
+			path:       "/test_alias/qr?size=100000",
+			statusCode: http.StatusBadRequest,
+			respError:  "field size must be between 64 and 2048",
+		},
+		{
+			name:       "Unknown level",
+			path:       "/test_alias/qr?level=X",
+			statusCode: http.StatusBadRequest,
+			respError:  "field level must be one of L M Q H",
+		},
+		{
+			name:       "Not found",
+			path:       "/test_alias/qr",
+			mockError:  storage.ErrURLNotFound,
+			statusCode: http.StatusNotFound,
+			respError:  "not found",
+		},
+		{
+			name:       "Expired",
+			path:       "/test_alias/qr",
+			mockError:  storage.ErrURLExpired,
+			statusCode: http.StatusGone,
+			respError:  "url is gone",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			if tc.statusCode != http.StatusBadRequest {
+				urlGetterMock.On("GetURL", mock.Anything, "", "test_alias").
+					Return(storage.URL{Alias: "test_alias", URL: "https://google.com"}, tc.mockError).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.Use(middleware.URLFormat)
+			r.Get("/{alias}/qr", qr.New(slogdiscard.NewDiscardLogger(), urlGetterMock, "https://s.example/", nil))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			require.Equal(t, tc.statusCode, rr.Code)
+
+			if tc.respError != "" {
+				var body response.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				require.Equal(t, tc.respError, body.Error)
+
+				return
+			}
+
+			require.Equal(t, tc.contentType, rr.Header().Get("Content-Type"))
+			require.NotEmpty(t, rr.Header().Get("ETag"))
+
+			if tc.contentType == "image/svg+xml" {
+				require.True(t, strings.HasPrefix(rr.Body.String(), "<svg "))
+				require.Contains(t, rr.Body.String(), `width="256"`)
+			}
+		})
+	}
+}
+
+func TestQRHandler_PNG(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", mock.Anything, "", "test_alias").
+		Return(storage.URL{Alias: "test_alias", URL: "https://google.com"}, nil)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}/qr", qr.New(slogdiscard.NewDiscardLogger(), urlGetterMock, "https://s.example", nil))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test_alias/qr?size=300&margin=2", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	img, err := png.Decode(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, 300, img.Bounds().Dx())
+	require.Equal(t, 300, img.Bounds().Dy())
+
+	// corners are quiet zone, finder pattern starts right after it
+	isDark := func(x, y int) bool {
+		return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 128
+	}
+	require.False(t, isDark(0, 0))
+	require.False(t, isDark(299, 299))
+
+	// 29 modules of version 3 code plus margins are 33 modules of 9px
+	// centered in 300px
+	require.False(t, isDark(18, 18))
+	require.True(t, isDark(19, 19))
+	require.True(t, isDark(19+7*9-1, 19))
+	require.False(t, isDark(19+7*9, 19))
+
+	etag := rr.Header().Get("ETag")
+
+	t.Run("Not modified", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test_alias/qr?size=300&margin=2", nil)
+		req.Header.Set("If-None-Match", etag)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusNotModified, rr.Code)
+		require.Empty(t, rr.Body.Bytes())
+	})
+
+	t.Run("Other options", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test_alias/qr?size=300&margin=3", nil)
+		req.Header.Set("If-None-Match", etag)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NotEqual(t, etag, rr.Header().Get("ETag"))
+	})
+}
+
+func TestQRHandler_Domains(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", mock.Anything, "", "test_alias").
+		Return(storage.URL{Alias: "test_alias", URL: "https://google.com"}, nil).Once()
+	urlGetterMock.On("GetURL", mock.Anything, "go.example", "test_alias").
+		Return(storage.URL{Domain: "go.example", Alias: "test_alias", URL: "https://google.com"}, nil).Once()
+
+	r := chi.NewRouter()
+	r.Get("/{alias}/qr", qr.New(slogdiscard.NewDiscardLogger(), urlGetterMock, "https://s.example", domain.NewAllowlist([]string{"go.example"})))
+
+	etags := make([]string, 0, 2)
+	for _, host := range []string{"s.example", "go.example"} {
+		req := httptest.NewRequest(http.MethodGet, "/test_alias/qr", nil)
+		req.Host = host
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		etags = append(etags, rr.Header().Get("ETag"))
+	}
+
+	// codes of the same alias on different domains encode different urls
+	require.NotEqual(t, etags[0], etags[1])
+}
@@ -0,0 +1,109 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+const (
+	formatPNG = "png"
+	formatSVG = "svg"
+)
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// options of rendered QR code. Size is in pixels, margin in modules.
+type options struct {
+	format string
+	level  string
+	size   int
+	margin int
+}
+
+func contentType(format string) string {
+	if format == formatSVG {
+		return "image/svg+xml"
+	}
+
+	return "image/png"
+}
+
+// encode renders QR code of content in format of opts.
+func encode(content string, opts options) ([]byte, error) {
+	code, err := qrcode.New(content, levels[opts.level])
+	if err != nil {
+		return nil, err
+	}
+	// quiet zone is drawn with margin of opts instead
+	code.DisableBorder = true
+
+	bitmap := code.Bitmap()
+	if opts.format == formatSVG {
+		return renderSVG(bitmap, opts), nil
+	}
+
+	return renderPNG(bitmap, opts)
+}
+
+// renderPNG draws modules as squares of whole pixels centered in image
+// of opts.size, so the code stays sharp. Image is larger if code doesn't
+// fit in it with one pixel per module.
+func renderPNG(bitmap [][]bool, opts options) ([]byte, error) {
+	modules := len(bitmap) + 2*opts.margin
+	scale := max(opts.size/modules, 1)
+	size := max(opts.size, modules)
+	offset := (size-modules*scale)/2 + opts.margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderSVG draws dark modules as one path in view box of one unit per
+// module, scaled to opts.size.
+func renderSVG(bitmap [][]bool, opts options) []byte {
+	modules := len(bitmap) + 2*opts.margin
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.size, opts.size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+opts.margin, y+opts.margin)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes()
+}
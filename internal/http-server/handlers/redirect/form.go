@@ -0,0 +1,56 @@
+package redirect
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"url-shortener/internal/lib/logger/sl"
+)
+
+// passwordForm asks for password of protected url. It's posted to the
+// url it's served from, so it works behind any prefix.
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<form method="post">
+<p>This link is protected by password.</p>
+{{if .}}<p role="alert">{{.}}</p>
+{{end}}<input type="password" name="password" autocomplete="current-password" required autofocus>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+// renderPasswordForm responds with password form showing message,
+// if it's not empty.
+func renderPasswordForm(log *slog.Logger, w http.ResponseWriter, status int, message string) {
+	renderHTML(log, w, passwordForm, status, message)
+}
+
+// renderHTML responds with page rendered from tmpl. Pages are not cached,
+// they change with the url.
+func renderHTML(log *slog.Logger, w http.ResponseWriter, tmpl *template.Template, status int, data any) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Error("failed to render page", slog.String("template", tmpl.Name()), sl.Err(err))
+
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
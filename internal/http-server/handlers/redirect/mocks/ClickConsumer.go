@@ -0,0 +1,46 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ClickConsumer is an autogenerated mock type for the ClickConsumer type
+type ClickConsumer struct {
+	mock.Mock
+}
+
+// ConsumeClick provides a mock function with given fields: ctx, domain, alias
+func (_m *ClickConsumer) ConsumeClick(ctx context.Context, domain string, alias string) error {
+	ret := _m.Called(ctx, domain, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeClick")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, domain, alias)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewClickConsumer creates a new instance of ClickConsumer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewClickConsumer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ClickConsumer {
+	mock := &ClickConsumer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
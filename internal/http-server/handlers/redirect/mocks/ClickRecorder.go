@@ -0,0 +1,29 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// ClickRecorder is an autogenerated mock type for the ClickRecorder type
+type ClickRecorder struct {
+	mock.Mock
+}
+
+// Record provides a mock function with given fields: domain, alias, referrer, userAgent, ip
+func (_m *ClickRecorder) Record(domain string, alias string, referrer string, userAgent string, ip string) {
+	_m.Called(domain, alias, referrer, userAgent, ip)
+}
+
+// NewClickRecorder creates a new instance of ClickRecorder. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewClickRecorder(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ClickRecorder {
+	mock := &ClickRecorder{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
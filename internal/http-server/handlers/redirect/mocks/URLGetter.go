@@ -2,34 +2,40 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
 
 // URLGetter is an autogenerated mock type for the URLGetter type
 type URLGetter struct {
 	mock.Mock
 }
 
-// GetURL provides a mock function with given fields: alias
-func (_m *URLGetter) GetURL(alias string) (string, error) {
-	ret := _m.Called(alias)
+// GetURL provides a mock function with given fields: ctx, domain, alias
+func (_m *URLGetter) GetURL(ctx context.Context, domain string, alias string) (storage.URL, error) {
+	ret := _m.Called(ctx, domain, alias)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetURL")
 	}
 
-	var r0 string
+	var r0 storage.URL
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (string, error)); ok {
-		return rf(alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (storage.URL, error)); ok {
+		return rf(ctx, domain, alias)
 	}
-	if rf, ok := ret.Get(0).(func(string) string); ok {
-		r0 = rf(alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) storage.URL); ok {
+		r0 = rf(ctx, domain, alias)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(storage.URL)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(alias)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, domain, alias)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,75 @@
+package redirect
+
+import (
+	"github.com/go-chi/render"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/storage"
+)
+
+// Preview describes url on its preview page.
+type Preview struct {
+	resp.Response
+	Alias string `json:"alias"`
+	// URL is omitted for urls limited to MaxClicks redirects, it's
+	// disclosed only when one of the clicks is used.
+	URL       string    `json:"url,omitempty"`
+	Host      string    `json:"host"`
+	Title     string    `json:"title,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// previewPage shows where url leads. Like password form it's posted to
+// the url it's served from to continue to the url, so the click is
+// recorded by NewUnlock.
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{with .Title}}{{.}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+<h1>{{with .Title}}{{.}}{{else}}Link preview{{end}}</h1>
+{{if .URL}}<p>This link leads to <code>{{.URL}}</code></p>
+{{else}}<p>This link leads to a page on <code>{{.Host}}</code></p>
+{{end}}<p>Created on <time datetime="{{.CreatedAt.Format "2006-01-02T15:04:05Z07:00"}}">{{.CreatedAt.Format "January 2, 2006"}}</time></p>
+<form method="post">
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// renderPreview responds with preview page of u, or with Preview as JSON
+// if client accepts it.
+func renderPreview(log *slog.Logger, w http.ResponseWriter, r *http.Request, u storage.URL) {
+	p := Preview{
+		Response:  resp.OK(),
+		Alias:     u.Alias,
+		Title:     u.Title,
+		CreatedAt: u.CreatedAt.UTC(),
+	}
+	if parsed, err := url.Parse(u.URL); err == nil {
+		p.Host = parsed.Hostname()
+	}
+	if u.MaxClicks == 0 {
+		p.URL = u.URL
+	}
+
+	w.Header().Set("Vary", "Accept")
+
+	if render.GetAcceptedContentType(r) == render.ContentTypeJSON {
+		w.Header().Set("Cache-Control", "no-store")
+		render.JSON(w, r, p)
+
+		return
+	}
+
+	renderHTML(log, w, previewPage, http.StatusOK, p)
+}
@@ -1,25 +1,76 @@
 package redirect
 
 import (
+	"context"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/lib/tracing"
 	"url-shortener/internal/storage"
 )
 
+// maxFormSize limits size of submitted password form.
+const maxFormSize = 4 << 10
+
 // URLGetter is an interface for getting url by alias.
 //
 //go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLGetter
 type URLGetter interface {
-	GetURL(alias string) (string, error)
+	GetURL(ctx context.Context, domain, alias string) (storage.URL, error)
+}
+
+// ClickConsumer is an interface for using clicks of urls limited to
+// MaxClicks redirects.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=ClickConsumer
+type ClickConsumer interface {
+	ConsumeClick(ctx context.Context, domain, alias string) error
+}
+
+// ClickRecorder is an interface for recording redirects.
+// Record must not block, it's called on every redirect.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=ClickRecorder
+type ClickRecorder interface {
+	Record(domain, alias, referrer, userAgent, ip string)
+}
+
+// Options configures redirects of urls.
+type Options struct {
+	// DefaultType is HTTP status of redirects of urls saved without one.
+	DefaultType int
+	// PermanentMaxAge is how long clients may cache 301 and 308 redirects.
+	// Clicks served from cache are not recorded.
+	PermanentMaxAge time.Duration
+	// Domains are short domains besides the primary one. Aliases are
+	// looked up on domain of the request Host, or on the primary one if
+	// the host isn't listed.
+	Domains domain.Allowlist
 }
 
-func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+// New returns handler redirecting to url with given alias on domain of
+// the request. Urls protected
+// by password get a form asking for it instead, see NewUnlock. Urls with
+// preview flag and aliases ending with "+" get preview page of the url.
+func New(
+	log *slog.Logger,
+	urlGetter URLGetter,
+	clickConsumer ClickConsumer,
+	clickRecorder ClickRecorder,
+	opts Options,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.redirect.New"
 
@@ -28,36 +79,243 @@ func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		alias := chi.URLParam(r, "alias")
-		if alias == "" {
-			log.Info("alias is empty")
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("invalid request"))
+		alias, preview := strings.CutSuffix(chi.URLParam(r, "alias"), "+")
 
+		u, ok := getURL(ctx, log, w, r, urlGetter, opts.Domains.FromHost(r.Host), alias)
+		if !ok {
 			return
 		}
 
-		resURL, err := urlGetter.GetURL(alias)
-		if errors.Is(err, storage.ErrURLNotFound) {
-			log.Info("url not found", "alias", alias)
+		// preview would disclose protected url
+		if u.PasswordHash != "" {
+			log.Info("url is protected by password", slog.String("alias", u.Alias))
 
-			render.Status(r, http.StatusNotFound)
-			render.JSON(w, r, resp.Error("not found"))
+			renderPasswordForm(log, w, http.StatusOK, "")
 
 			return
 		}
-		if err != nil {
-			log.Info("failed to get url", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("internal server error"))
+		if preview || u.Preview {
+			log.Info("previewing url", slog.String("alias", u.Alias))
+
+			renderPreview(log, w, r, u)
+
+			return
+		}
 
+		if !consumeClick(ctx, log, w, r, clickConsumer, u) {
 			return
 		}
 
-		log.Info("got url", slog.String("url", resURL))
+		log.Info("got url", slog.String("url", u.URL))
+
+		clickRecorder.Record(u.Domain, u.Alias, r.Referer(), r.UserAgent(), clientIP(r))
+		metrics.RedirectsServed.Inc()
+
+		code := u.RedirectType
+		if code == 0 {
+			code = opts.DefaultType
+		}
+
+		w.Header().Set("Cache-Control", cacheControl(code, u, opts.PermanentMaxAge, time.Now()))
 
 		// redirect to found url
-		http.Redirect(w, r, resURL, http.StatusFound)
+		http.Redirect(w, r, u.URL, code)
+	}
+}
+
+// NewUnlock returns handler of the password form and the preview page
+// served by New. It resolves domain of the request like New and redirects
+// to url only if the posted password is correct, urls without password
+// are redirected to right away.
+func NewUnlock(
+	log *slog.Logger,
+	urlGetter URLGetter,
+	clickConsumer ClickConsumer,
+	clickRecorder ClickRecorder,
+	domains domain.Allowlist,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.redirect.NewUnlock"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		alias, _ := strings.CutSuffix(chi.URLParam(r, "alias"), "+")
+
+		u, ok := getURL(ctx, log, w, r, urlGetter, domains.FromHost(r.Host), alias)
+		if !ok {
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if err := r.ParseForm(); err != nil {
+			log.Info("failed to parse form", sl.Err(err))
+
+			renderPasswordForm(log, w, http.StatusBadRequest, "Invalid request.")
+
+			return
+		}
+
+		if u.PasswordHash != "" && !password.Matches(u.PasswordHash, r.PostForm.Get("password")) {
+			log.Info("wrong password", slog.String("alias", u.Alias))
+
+			renderPasswordForm(log, w, http.StatusUnauthorized, "Wrong password.")
+
+			return
+		}
+
+		if !consumeClick(ctx, log, w, r, clickConsumer, u) {
+			return
+		}
+
+		log.Info("got url", slog.String("url", u.URL))
+
+		clickRecorder.Record(u.Domain, u.Alias, r.Referer(), r.UserAgent(), clientIP(r))
+		metrics.RedirectsServed.Inc()
+
+		// the url is unlocked for this request only
+		w.Header().Set("Cache-Control", "no-store")
+
+		// 303 makes the browser follow with GET, 307 and 308 would repost the password
+		http.Redirect(w, r, u.URL, http.StatusSeeOther)
+	}
+}
+
+// getURL responds with error and returns false unless url with alias
+// exists and hasn't expired.
+func getURL(
+	ctx context.Context,
+	log *slog.Logger,
+	w http.ResponseWriter,
+	r *http.Request,
+	urlGetter URLGetter,
+	domain, alias string,
+) (storage.URL, bool) {
+	if alias == "" {
+		log.Info("alias is empty")
+
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("invalid request"))
+
+		return storage.URL{}, false
+	}
+
+	u, err := urlGetter.GetURL(ctx, domain, alias)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		log.Info("url not found", "domain", domain, "alias", alias)
+
+		metrics.RedirectsNotFound.Inc()
+
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("not found"))
+
+		return storage.URL{}, false
+	}
+	if errors.Is(err, storage.ErrURLExpired) {
+		log.Info("url expired", "alias", alias)
+
+		render.Status(r, http.StatusGone)
+		render.JSON(w, r, resp.Error("url expired"))
+
+		return storage.URL{}, false
+	}
+	if errors.Is(err, storage.ErrURLExhausted) {
+		log.Info("url has no clicks left", "alias", alias)
+
+		render.Status(r, http.StatusGone)
+		render.JSON(w, r, resp.Error("url has no clicks left"))
+
+		return storage.URL{}, false
+	}
+	if err != nil {
+		log.Info("failed to get url", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("internal server error"))
+
+		return storage.URL{}, false
+	}
+
+	return u, true
+}
+
+// consumeClick uses a click of url limited to MaxClicks redirects.
+// It responds with error and returns false if there's none left.
+func consumeClick(
+	ctx context.Context,
+	log *slog.Logger,
+	w http.ResponseWriter,
+	r *http.Request,
+	clickConsumer ClickConsumer,
+	u storage.URL,
+) bool {
+	if u.MaxClicks == 0 {
+		return true
 	}
+
+	err := clickConsumer.ConsumeClick(ctx, u.Domain, u.Alias)
+	if errors.Is(err, storage.ErrURLExhausted) {
+		// another redirect took the last click since the url was read
+		log.Info("url has no clicks left", "alias", u.Alias)
+
+		render.Status(r, http.StatusGone)
+		render.JSON(w, r, resp.Error("url has no clicks left"))
+
+		return false
+	}
+	if errors.Is(err, storage.ErrURLNotFound) {
+		log.Info("url not found", "alias", u.Alias)
+
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("not found"))
+
+		return false
+	}
+	if err != nil {
+		log.Error("failed to consume click", sl.Err(err))
+
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, resp.Error("internal server error"))
+
+		return false
+	}
+
+	return true
+}
+
+// cacheControl returns Cache-Control header of redirect of u with given
+// status. Permanent redirects are cached until the url expires at most,
+// temporary ones and ones of urls with limited clicks are not cached so
+// every click reaches the server.
+func cacheControl(code int, u storage.URL, maxAge time.Duration, now time.Time) string {
+	if u.MaxClicks > 0 {
+		return "no-store"
+	}
+
+	if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+		return "private, max-age=0"
+	}
+
+	if u.ExpiresAt != nil && u.ExpiresAt.Sub(now) < maxAge {
+		maxAge = u.ExpiresAt.Sub(now)
+	}
+
+	return "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
 }
@@ -1,28 +1,109 @@
 package redirect_test
 
 import (
+	"encoding/json"
 	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 	"url-shortener/internal/http-server/handlers/redirect"
 	"url-shortener/internal/http-server/handlers/redirect/mocks"
-	"url-shortener/internal/lib/api"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/storage"
 )
 
 func TestRedirectHandler(t *testing.T) {
 	cases := []struct {
-		name      string
-		alias     string
-		url       string
-		respError string
-		mockError error
+		name         string
+		alias        string
+		url          string
+		redirectType int
+		expiresAt    *time.Time
+		maxClicks    int64
+		respError    string
+		mockError    error
+		consumeError error
+		statusCode   int
+		cacheControl string
 	}{
 		{
-			name:  "Success",
-			alias: "test_alias",
-			url:   "https://www.google.com/",
+			name:         "Success",
+			alias:        "test_alias",
+			url:          "https://www.google.com/",
+			statusCode:   http.StatusFound,
+			cacheControl: "private, max-age=0",
+		},
+		{
+			name:         "Temporary preserving method",
+			alias:        "test_alias",
+			url:          "https://www.google.com/",
+			redirectType: http.StatusTemporaryRedirect,
+			statusCode:   http.StatusTemporaryRedirect,
+			cacheControl: "private, max-age=0",
+		},
+		{
+			name:         "Moved permanently",
+			alias:        "test_alias",
+			url:          "https://www.google.com/",
+			redirectType: http.StatusMovedPermanently,
+			statusCode:   http.StatusMovedPermanently,
+			cacheControl: "public, max-age=3600",
+		},
+		{
+			name:         "Permanent until expiration",
+			alias:        "test_alias",
+			url:          "https://www.google.com/",
+			redirectType: http.StatusPermanentRedirect,
+			expiresAt:    ptr(time.Now().Add(10*time.Minute + 30*time.Second + 500*time.Millisecond)),
+			statusCode:   http.StatusPermanentRedirect,
+			cacheControl: "public, max-age=630",
+		},
+		{
+			name:         "Limited clicks",
+			alias:        "test_alias",
+			url:          "https://www.google.com/",
+			redirectType: http.StatusMovedPermanently,
+			maxClicks:    1,
+			statusCode:   http.StatusMovedPermanently,
+			cacheControl: "no-store",
+		},
+		{
+			name:         "Last click taken",
+			alias:        "test_alias",
+			url:          "https://www.google.com/",
+			maxClicks:    1,
+			respError:    "url has no clicks left",
+			consumeError: storage.ErrURLExhausted,
+			statusCode:   http.StatusGone,
+		},
+		{
+			name:       "Not found",
+			alias:      "test_alias",
+			respError:  "not found",
+			mockError:  storage.ErrURLNotFound,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "Expired",
+			alias:      "test_alias",
+			respError:  "url expired",
+			mockError:  storage.ErrURLExpired,
+			statusCode: http.StatusGone,
+		},
+		{
+			name:       "Exhausted",
+			alias:      "test_alias",
+			respError:  "url has no clicks left",
+			mockError:  storage.ErrURLExhausted,
+			statusCode: http.StatusGone,
 		},
 	}
 
@@ -30,22 +111,368 @@ func TestRedirectHandler(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			urlGetterMock := mocks.NewURLGetter(t)
 
-			if tc.respError == "" || tc.mockError != nil {
-				urlGetterMock.On("GetURL", tc.alias).
-					Return(tc.url, tc.mockError).Once()
+			u := storage.URL{
+				Alias:        tc.alias,
+				URL:          tc.url,
+				RedirectType: tc.redirectType,
+				ExpiresAt:    tc.expiresAt,
+				MaxClicks:    tc.maxClicks,
+			}
+			urlGetterMock.On("GetURL", mock.Anything, "", tc.alias).
+				Return(u, tc.mockError).Once()
+
+			clickConsumerMock := mocks.NewClickConsumer(t)
+			if tc.maxClicks > 0 {
+				clickConsumerMock.On("ConsumeClick", mock.Anything, "", tc.alias).
+					Return(tc.consumeError).Once()
+			}
+
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			if tc.respError == "" {
+				clickRecorderMock.On("Record", "", tc.alias, "", mock.AnythingOfType("string"), "127.0.0.1").
+					Once()
 			}
 
 			r := chi.NewRouter()
-			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock))
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickConsumerMock, clickRecorderMock, redirect.Options{
+				DefaultType:     http.StatusFound,
+				PermanentMaxAge: time.Hour,
+			}))
 
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 
-			redirectedToURL, err := api.GetRedirect(ts.URL + "/" + tc.alias)
+			if tc.respError != "" {
+				resp, err := http.Get(ts.URL + "/" + tc.alias)
+				require.NoError(t, err)
+				defer func() { _ = resp.Body.Close() }()
+
+				require.Equal(t, tc.statusCode, resp.StatusCode)
+
+				var body response.Response
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				require.Equal(t, tc.respError, body.Error)
+
+				return
+			}
+
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			resp, err := client.Get(ts.URL + "/" + tc.alias)
 			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			require.Equal(t, tc.statusCode, resp.StatusCode)
+			require.Equal(t, tc.cacheControl, resp.Header.Get("Cache-Control"))
 
 			// Check the final URL after redirection.
-			require.Equal(t, tc.url, redirectedToURL)
+			require.Equal(t, tc.url, resp.Header.Get("Location"))
 		})
 	}
 }
+
+func TestRedirectHandler_Protected(t *testing.T) {
+	hash, err := password.Hash("secret")
+	require.NoError(t, err)
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", mock.Anything, "", "test_alias").
+		Return(storage.URL{Alias: "test_alias", URL: "https://www.google.com/", PasswordHash: hash}, nil).Once()
+
+	// the click is recorded once the password is posted
+	clickRecorderMock := mocks.NewClickRecorder(t)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, mocks.NewClickConsumer(t), clickRecorderMock, redirect.Options{
+		DefaultType: http.StatusFound,
+	}))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test_alias", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	require.Contains(t, rr.Body.String(), `<form method="post">`)
+	require.NotContains(t, rr.Body.String(), "https://www.google.com/")
+}
+
+func TestRedirectHandler_Preview(t *testing.T) {
+	createdAt := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		path        string
+		accept      string
+		url         storage.URL
+		statusCode  int
+		contentType string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "Plus suffix",
+			path:        "/test_alias+",
+			url:         storage.URL{Alias: "test_alias", URL: "https://www.google.com/search", CreatedAt: createdAt},
+			statusCode:  http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{"<title>Link preview</title>", "https://www.google.com/search", "May 6, 2024", `<form method="post">`},
+		},
+		{
+			name:        "Preview flag",
+			path:        "/test_alias",
+			url:         storage.URL{Alias: "test_alias", URL: "https://www.google.com/", CreatedAt: createdAt, Title: "<Search>", Preview: true},
+			statusCode:  http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{"<h1>&lt;Search&gt;</h1>", "https://www.google.com/"},
+		},
+		{
+			name:        "JSON",
+			path:        "/test_alias+",
+			accept:      "application/json",
+			url:         storage.URL{Alias: "test_alias", URL: "https://www.google.com/", CreatedAt: createdAt, Title: "Search"},
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			contains: []string{
+				`"alias":"test_alias"`, `"url":"https://www.google.com/"`, `"host":"www.google.com"`,
+				`"title":"Search"`, `"created_at":"2024-05-06T07:08:09Z"`,
+			},
+		},
+		{
+			name:        "Limited clicks",
+			path:        "/test_alias+",
+			url:         storage.URL{Alias: "test_alias", URL: "https://www.google.com/secret", CreatedAt: createdAt, MaxClicks: 1},
+			statusCode:  http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{"a page on <code>www.google.com</code>"},
+			notContains: []string{"/secret"},
+		},
+		{
+			name:        "Protected",
+			path:        "/test_alias+",
+			url:         storage.URL{Alias: "test_alias", URL: "https://www.google.com/secret", PasswordHash: "hash"},
+			statusCode:  http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{`name="password"`},
+			notContains: []string{"/secret"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetURL", mock.Anything, "", "test_alias").
+				Return(tc.url, nil).Once()
+
+			// previews are not clicks
+			clickConsumerMock := mocks.NewClickConsumer(t)
+			clickRecorderMock := mocks.NewClickRecorder(t)
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickConsumerMock, clickRecorderMock, redirect.Options{
+				DefaultType: http.StatusFound,
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.statusCode, rr.Code)
+			require.Equal(t, tc.contentType, rr.Header().Get("Content-Type"))
+			require.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+			for _, s := range tc.contains {
+				require.Contains(t, rr.Body.String(), s)
+			}
+			for _, s := range tc.notContains {
+				require.NotContains(t, rr.Body.String(), s)
+			}
+		})
+	}
+}
+
+func TestUnlockHandler(t *testing.T) {
+	hash, err := password.Hash("secret")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name         string
+		path         string
+		passwordHash string
+		password     string
+		maxClicks    int64
+		mockError    error
+		consumeError error
+		statusCode   int
+		respError    string
+	}{
+		{
+			name:         "Correct password",
+			passwordHash: hash,
+			password:     "secret",
+			statusCode:   http.StatusSeeOther,
+		},
+		{
+			name:         "Wrong password",
+			passwordHash: hash,
+			password:     "Secret",
+			statusCode:   http.StatusUnauthorized,
+		},
+		{
+			name:         "Empty password",
+			passwordHash: hash,
+			statusCode:   http.StatusUnauthorized,
+		},
+		{
+			name:       "Not protected",
+			password:   "anything",
+			statusCode: http.StatusSeeOther,
+		},
+		{
+			name:       "Continued from preview",
+			path:       "/test_alias+",
+			statusCode: http.StatusSeeOther,
+		},
+		{
+			name:       "Not found",
+			password:   "secret",
+			mockError:  storage.ErrURLNotFound,
+			statusCode: http.StatusNotFound,
+			respError:  "not found",
+		},
+		{
+			name:         "One-time",
+			passwordHash: hash,
+			password:     "secret",
+			maxClicks:    1,
+			statusCode:   http.StatusSeeOther,
+		},
+		{
+			name:         "One-time already used",
+			passwordHash: hash,
+			password:     "secret",
+			maxClicks:    1,
+			consumeError: storage.ErrURLExhausted,
+			statusCode:   http.StatusGone,
+			respError:    "url has no clicks left",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			u := storage.URL{
+				Alias:        "test_alias",
+				URL:          "https://www.google.com/",
+				PasswordHash: tc.passwordHash,
+				MaxClicks:    tc.maxClicks,
+			}
+			urlGetterMock.On("GetURL", mock.Anything, "", "test_alias").
+				Return(u, tc.mockError).Once()
+
+			// clicks are used only after the password is checked
+			clickConsumerMock := mocks.NewClickConsumer(t)
+			if tc.maxClicks > 0 && tc.statusCode != http.StatusUnauthorized {
+				clickConsumerMock.On("ConsumeClick", mock.Anything, "", "test_alias").
+					Return(tc.consumeError).Once()
+			}
+
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			if tc.statusCode == http.StatusSeeOther {
+				clickRecorderMock.On("Record", "", "test_alias", "", "", "192.0.2.1").Once()
+			}
+
+			r := chi.NewRouter()
+			r.Post("/{alias}", redirect.NewUnlock(slogdiscard.NewDiscardLogger(), urlGetterMock, clickConsumerMock, clickRecorderMock, nil))
+
+			form := url.Values{"password": {tc.password}}
+			path := tc.path
+			if path == "" {
+				path = "/test_alias"
+			}
+
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.statusCode, rr.Code)
+
+			switch {
+			case tc.respError != "":
+				var body response.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				require.Equal(t, tc.respError, body.Error)
+			case tc.statusCode == http.StatusSeeOther:
+				require.Equal(t, "https://www.google.com/", rr.Header().Get("Location"))
+				require.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+			default:
+				require.Contains(t, rr.Body.String(), "Wrong password.")
+				require.NotContains(t, rr.Body.String(), "https://www.google.com/")
+			}
+		})
+	}
+}
+
+func TestRedirectHandler_Domains(t *testing.T) {
+	cases := []struct {
+		name   string
+		host   string
+		domain string
+	}{
+		{name: "Custom domain", host: "go.example", domain: "go.example"},
+		{name: "Custom domain with port", host: "GO.example:8082", domain: "go.example"},
+		{name: "Primary domain", host: "localhost:8082", domain: ""},
+		{name: "Unknown domain", host: "other.example", domain: ""},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetURL", mock.Anything, tc.domain, "test_alias").
+				Return(storage.URL{Domain: tc.domain, Alias: "test_alias", URL: "https://www.google.com/"}, nil).Once()
+
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			clickRecorderMock.On("Record", tc.domain, "test_alias", "", "", "192.0.2.1").Once()
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, mocks.NewClickConsumer(t), clickRecorderMock, redirect.Options{
+				DefaultType: http.StatusFound,
+				Domains:     domain.NewAllowlist([]string{"go.example"}),
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/test_alias", nil)
+			req.Host = tc.host
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusFound, rr.Code)
+			require.Equal(t, "https://www.google.com/", rr.Header().Get("Location"))
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
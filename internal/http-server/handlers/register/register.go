@@ -11,6 +11,7 @@ import (
 	"net/http"
 	resp "url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
 )
 
 type Request struct {
@@ -36,6 +37,9 @@ func New(log *slog.Logger, userRegisterer UserRegisterer) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
 		var req Request
 
 		err := render.DecodeJSON(r.Body, &req)
@@ -62,7 +66,7 @@ func New(log *slog.Logger, userRegisterer UserRegisterer) http.HandlerFunc {
 			return
 		}
 
-		userId, err := userRegisterer.Register(context.Background(), req.Email, req.Password)
+		userId, err := userRegisterer.Register(ctx, req.Email, req.Password)
 		if err != nil {
 			log.Error("failed to register user", sl.Err(err))
 
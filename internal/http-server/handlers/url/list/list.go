@@ -0,0 +1,232 @@
+package list
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+)
+
+type URL struct {
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	Clicks    int64      `json:"clicks"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// RedirectType is omitted for urls redirected with the configured default.
+	RedirectType int `json:"redirect_type,omitempty"`
+	// Protected tells that url asks for password before redirecting.
+	Protected bool `json:"protected,omitempty"`
+	// MaxClicks and UsedClicks are omitted for urls with unlimited clicks.
+	MaxClicks  int64  `json:"max_clicks,omitempty"`
+	UsedClicks int64  `json:"used_clicks,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Preview    bool   `json:"preview,omitempty"`
+	// Domain is omitted for urls on the primary domain.
+	Domain string `json:"domain,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	URLs       []URL  `json:"urls"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// URLLister is an interface for listing urls created by user.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLLister
+type URLLister interface {
+	ListURLs(ctx context.Context, userID int64, params storage.ListURLsParams) ([]storage.URL, error)
+}
+
+// New returns handler listing urls of the authenticated user.
+//
+// Query parameters:
+//   - limit: page size, 1..100, default 20
+//   - sort: created_at (default) or alias
+//   - order: desc (default) or asc
+//   - cursor: next_cursor from the previous page
+func New(log *slog.Logger, urlLister URLLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.list.New"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		userId, ok := authenticator.UserIdFromContext(r.Context())
+		if !ok {
+			log.Info("failed to get userId from context")
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		params, err := parseParams(r)
+		if err != nil {
+			log.Info("invalid request", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		limit := params.Limit
+		// fetch one extra url to know if there is a next page
+		params.Limit++
+
+		urls, err := urlLister.ListURLs(ctx, userId, params)
+		if err != nil {
+			log.Error("failed to list urls", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		var nextCursor string
+		if len(urls) > limit {
+			urls = urls[:limit]
+			nextCursor = encodeCursor(params.SortBy, urls[limit-1])
+		}
+
+		res := make([]URL, 0, len(urls))
+		for _, u := range urls {
+			res = append(res, URL{
+				Alias:        u.Alias,
+				URL:          u.URL,
+				CreatedAt:    u.CreatedAt,
+				Clicks:       u.Clicks,
+				ExpiresAt:    u.ExpiresAt,
+				RedirectType: u.RedirectType,
+				Protected:    u.PasswordHash != "",
+				MaxClicks:    u.MaxClicks,
+				UsedClicks:   u.UsedClicks,
+				Title:        u.Title,
+				Preview:      u.Preview,
+				Domain:       u.Domain,
+			})
+		}
+
+		log.Info("urls listed", slog.Int("count", len(res)))
+
+		render.JSON(w, r, Response{
+			Response:   resp.OK(),
+			URLs:       res,
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+func parseParams(r *http.Request) (storage.ListURLsParams, error) {
+	query := r.URL.Query()
+
+	params := storage.ListURLsParams{
+		SortBy: storage.SortByCreatedAt,
+		Desc:   true,
+		Limit:  defaultLimit,
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxLimit {
+			return params, errors.New("field limit must be between 1 and 100")
+		}
+		params.Limit = n
+	}
+
+	switch sortBy := query.Get("sort"); sortBy {
+	case "", storage.SortByCreatedAt:
+	case storage.SortByAlias:
+		params.SortBy = sortBy
+	default:
+		return params, errors.New("field sort must be one of: created_at, alias")
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		params.Desc = false
+	default:
+		return params, errors.New("field order must be one of: asc, desc")
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, afterID, err := decodeCursor(params.SortBy, cursor)
+		if err != nil {
+			return params, err
+		}
+		params.After, params.AfterID = after, afterID
+	}
+
+	return params, nil
+}
+
+// encodeCursor returns opaque cursor pointing after given url.
+// The cursor is bound to the sort field it was created for and also
+// holds url id, urls with the same sort key are ordered by id.
+func encodeCursor(sortBy string, u storage.URL) string {
+	key := u.Alias
+	if sortBy == storage.SortByCreatedAt {
+		key = u.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	raw := sortBy + ":" + strconv.FormatInt(u.ID, 10) + ":" + key
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor returns sort key and id of the url the cursor points after.
+func decodeCursor(sortBy string, cursor string) (string, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+
+	field, key, ok := strings.Cut(string(raw), ":")
+	if !ok || field != sortBy || key == "" {
+		return "", 0, ErrInvalidCursor
+	}
+
+	rawID, after, ok := strings.Cut(key, ":")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if !ok || err != nil || after == "" {
+		return "", 0, ErrInvalidCursor
+	}
+
+	if sortBy == storage.SortByCreatedAt {
+		if _, err = time.Parse(time.RFC3339Nano, after); err != nil {
+			return "", 0, ErrInvalidCursor
+		}
+	}
+
+	return after, id, nil
+}
@@ -0,0 +1,193 @@
+package list_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/list"
+	"url-shortener/internal/http-server/handlers/url/list/mocks"
+	mocks2 "url-shortener/internal/http-server/middleware/authenticator/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestListHandler(t *testing.T) {
+	const userId = int64(42)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	urls := []storage.URL{
+		{ID: 3, Alias: "c", URL: "https://c.com", UserID: userId, CreatedAt: createdAt, Clicks: 3},
+		{ID: 2, Alias: "b", URL: "https://b.com", UserID: userId, CreatedAt: createdAt, Clicks: 2},
+		{ID: 1, Alias: "a", URL: "https://a.com", UserID: userId, CreatedAt: createdAt, Clicks: 1},
+	}
+
+	cases := []struct {
+		name         string
+		query        string
+		shouldList   bool
+		params       storage.ListURLsParams
+		mockURLs     []storage.URL
+		mockError    error
+		statusCode   int
+		respError    string
+		respAliases  []string
+		respNextPage string
+	}{
+		{
+			name:        "Success with defaults",
+			query:       "",
+			shouldList:  true,
+			params:      storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Desc: true, Limit: 21},
+			mockURLs:    urls,
+			statusCode:  http.StatusOK,
+			respAliases: []string{"c", "b", "a"},
+		},
+		{
+			name:         "Has next page",
+			query:        "?limit=2",
+			shouldList:   true,
+			params:       storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Desc: true, Limit: 3},
+			mockURLs:     urls,
+			statusCode:   http.StatusOK,
+			respAliases:  []string{"c", "b"},
+			respNextPage: base64.RawURLEncoding.EncodeToString([]byte("created_at:2:2024-01-02T03:04:05Z")),
+		},
+		{
+			name:       "Next page by alias",
+			query:      "?sort=alias&order=asc&limit=1&cursor=" + base64.RawURLEncoding.EncodeToString([]byte("alias:1:a")),
+			shouldList: true,
+			params: storage.ListURLsParams{
+				SortBy:  storage.SortByAlias,
+				Limit:   2,
+				After:   "a",
+				AfterID: 1,
+			},
+			mockURLs:     []storage.URL{urls[1], urls[0]},
+			statusCode:   http.StatusOK,
+			respAliases:  []string{"b"},
+			respNextPage: base64.RawURLEncoding.EncodeToString([]byte("alias:2:b")),
+		},
+		{
+			name:       "Next page by creation",
+			query:      "?limit=1&cursor=" + base64.RawURLEncoding.EncodeToString([]byte("created_at:3:2024-01-02T03:04:05Z")),
+			shouldList: true,
+			params: storage.ListURLsParams{
+				SortBy:  storage.SortByCreatedAt,
+				Desc:    true,
+				Limit:   2,
+				After:   "2024-01-02T03:04:05Z",
+				AfterID: 3,
+			},
+			mockURLs:    urls[1:2],
+			statusCode:  http.StatusOK,
+			respAliases: []string{"b"},
+		},
+		{
+			name:        "Empty list",
+			query:       "",
+			shouldList:  true,
+			params:      storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Desc: true, Limit: 21},
+			statusCode:  http.StatusOK,
+			respAliases: []string{},
+		},
+		{
+			name:       "Invalid limit",
+			query:      "?limit=1000",
+			statusCode: http.StatusBadRequest,
+			respError:  "field limit must be between 1 and 100",
+		},
+		{
+			name:       "Invalid sort",
+			query:      "?sort=url",
+			statusCode: http.StatusBadRequest,
+			respError:  "field sort must be one of: created_at, alias",
+		},
+		{
+			name:       "Invalid order",
+			query:      "?order=random",
+			statusCode: http.StatusBadRequest,
+			respError:  "field order must be one of: asc, desc",
+		},
+		{
+			name:       "Cursor for another sort",
+			query:      "?cursor=" + base64.RawURLEncoding.EncodeToString([]byte("alias:a")),
+			statusCode: http.StatusBadRequest,
+			respError:  "invalid cursor",
+		},
+		{
+			name:       "Alias cursor without id",
+			query:      "?sort=alias&cursor=" + base64.RawURLEncoding.EncodeToString([]byte("alias:a")),
+			statusCode: http.StatusBadRequest,
+			respError:  "invalid cursor",
+		},
+		{
+			name:       "Creation cursor without time",
+			query:      "?cursor=" + base64.RawURLEncoding.EncodeToString([]byte("created_at:2:yesterday")),
+			statusCode: http.StatusBadRequest,
+			respError:  "invalid cursor",
+		},
+		{
+			name:       "Malformed cursor",
+			query:      "?cursor=%25%25%25",
+			statusCode: http.StatusBadRequest,
+			respError:  "invalid cursor",
+		},
+		{
+			name:       "ListURLs Error",
+			query:      "",
+			shouldList: true,
+			params:     storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Desc: true, Limit: 21},
+			mockError:  errors.New("unexpected error"),
+			statusCode: http.StatusInternalServerError,
+			respError:  "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlListerMock := mocks.NewURLLister(t)
+			if tc.shouldList {
+				urlListerMock.On("ListURLs", mock.Anything, userId, tc.params).
+					Return(tc.mockURLs, tc.mockError).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Get("/url", list.New(slogdiscard.NewDiscardLogger(), urlListerMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/url"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.statusCode, rr.Code)
+
+			var resp list.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Error)
+			require.Equal(t, tc.respNextPage, resp.NextCursor)
+
+			if tc.respError == "" {
+				aliases := make([]string, 0, len(resp.URLs))
+				for _, u := range resp.URLs {
+					aliases = append(aliases, u.Alias)
+				}
+				require.Equal(t, tc.respAliases, aliases)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// URLLister is an autogenerated mock type for the URLLister type
+type URLLister struct {
+	mock.Mock
+}
+
+// ListURLs provides a mock function with given fields: ctx, userID, params
+func (_m *URLLister) ListURLs(ctx context.Context, userID int64, params storage.ListURLsParams) ([]storage.URL, error) {
+	ret := _m.Called(ctx, userID, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListURLs")
+	}
+
+	var r0 []storage.URL
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, storage.ListURLsParams) ([]storage.URL, error)); ok {
+		return rf(ctx, userID, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, storage.ListURLsParams) []storage.URL); ok {
+		r0 = rf(ctx, userID, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.URL)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, storage.ListURLsParams) error); ok {
+		r1 = rf(ctx, userID, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLLister creates a new instance of URLLister. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLLister(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLLister {
+	mock := &URLLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
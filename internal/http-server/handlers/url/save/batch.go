@@ -0,0 +1,278 @@
+package save
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	"url-shortener/internal/lib/alias"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+)
+
+const (
+	// MaxBatchSize is the maximum number of urls saved by one batch request.
+	MaxBatchSize = 1000
+	// MaxBatchPasswords is the maximum number of password protected urls
+	// of one batch request, hashing of every password takes tens of ms.
+	MaxBatchPasswords = 10
+)
+
+// BatchResponse contains results of saving urls in order of request.
+// Status is OK when the batch was processed, even if some urls failed.
+type BatchResponse struct {
+	resp.Response
+	Results []Response `json:"results,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLBatchSaver
+type URLBatchSaver interface {
+	SaveURLs(ctx context.Context, userID int64, urls []storage.URLToSave) ([]storage.SaveResult, error)
+}
+
+// batchItem is a url of batch that is not saved yet.
+type batchItem struct {
+	// index is position of the url in request.
+	index int
+	url   string
+	alias string
+	opts  storage.URLOptions
+	// generator is nil if alias is given in request.
+	generator *aliasGenerator
+	attempt   aliasAttempt
+}
+
+// NewBatch returns handler saving array of urls in one request. Every url
+// is saved or fails on its own, results are returned in order of request.
+func NewBatch(log *slog.Logger, urlSaver URLBatchSaver, aliasOpts AliasOptions, domains domain.Allowlist) http.HandlerFunc {
+	generators := newAliasGenerators(aliasOpts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.save.NewBatch"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		userId, ok := authenticator.UserIdFromContext(r.Context())
+		if !ok {
+			log.Info("failed to get userId from context")
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		var reqs []Request
+
+		err := render.DecodeJSON(r.Body, &reqs)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		if len(reqs) == 0 || len(reqs) > MaxBatchSize {
+			log.Info("invalid batch size", slog.Int("size", len(reqs)))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("batch must contain from 1 to %d urls", MaxBatchSize)))
+
+			return
+		}
+
+		passwords := 0
+		for _, req := range reqs {
+			if req.Password != "" {
+				passwords++
+			}
+		}
+		if passwords > MaxBatchPasswords {
+			log.Info("too many passwords in batch", slog.Int("passwords", passwords))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("batch must contain at most %d password protected urls", MaxBatchPasswords)))
+
+			return
+		}
+
+		results := make([]Response, len(reqs))
+		pending := make([]*batchItem, 0, len(reqs))
+
+		validate := validator.New()
+		now := time.Now()
+		for i, req := range reqs {
+			item, errResp := prepareBatchItem(validate, generators, aliasOpts.Strategy, domains, req, now)
+			if item == nil {
+				results[i] = Response{Response: errResp}
+				continue
+			}
+
+			item.index = i
+			pending = append(pending, item)
+		}
+
+		created := 0
+		// generated aliases that collided are retried in the next round
+		for round := 0; round < aliasAttempts && len(pending) > 0; round++ {
+			toSave := make([]storage.URLToSave, 0, len(pending))
+			saving := make([]*batchItem, 0, len(pending))
+			for _, item := range pending {
+				if item.generator != nil {
+					item.alias, err = item.generator.generate(ctx, &item.attempt)
+					if err != nil {
+						log.Error("failed to save url", sl.Err(err))
+
+						results[item.index] = Response{Response: resp.Error("failed to save url")}
+						continue
+					}
+				}
+
+				toSave = append(toSave, storage.URLToSave{URL: item.url, Alias: item.alias, Opts: item.opts})
+				saving = append(saving, item)
+			}
+			if len(saving) == 0 {
+				pending = nil
+
+				break
+			}
+
+			saved, err := urlSaver.SaveURLs(ctx, userId, toSave)
+			if err != nil {
+				log.Error("failed to save urls", sl.Err(err))
+
+				for _, item := range saving {
+					results[item.index] = Response{Response: resp.Error("failed to save url")}
+				}
+				pending = nil
+
+				break
+			}
+
+			pending = pending[:0]
+			for i, item := range saving {
+				switch {
+				case saved[i].Err == nil:
+					results[item.index] = Response{Response: resp.OK(), Alias: item.alias}
+					created++
+				case !errors.Is(saved[i].Err, storage.ErrURLExists):
+					log.Error("failed to save url", sl.Err(saved[i].Err))
+
+					results[item.index] = Response{Response: resp.Error("failed to save url")}
+				case item.generator == nil:
+					results[item.index] = Response{Response: resp.Error("alias already exists")}
+				default:
+					item.generator.collided(&item.attempt)
+					pending = append(pending, item)
+				}
+			}
+		}
+
+		for _, item := range pending {
+			log.Error("failed to generate alias", sl.Err(ErrNoFreeAlias))
+
+			results[item.index] = Response{Response: resp.Error("failed to generate alias")}
+		}
+
+		log.Info("urls added", slog.Int("requested", len(reqs)), slog.Int("created", created))
+
+		metrics.LinksCreated.Add(float64(created))
+
+		render.JSON(w, r, BatchResponse{
+			Response: resp.OK(),
+			Results:  results,
+		})
+	}
+}
+
+// prepareBatchItem validates url of batch. It returns error response
+// instead of item if the url can't be saved.
+func prepareBatchItem(
+	validate *validator.Validate,
+	generators map[string]*aliasGenerator,
+	defaultStrategy string,
+	domains domain.Allowlist,
+	req Request,
+	now time.Time,
+) (*batchItem, resp.Response) {
+	if err := validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+
+		return nil, resp.ValidationError(validateErr)
+	}
+
+	if req.Alias != "" {
+		if err := alias.Validate(req.Alias); err != nil {
+			return nil, resp.Error(err.Error())
+		}
+	}
+
+	expiresAt, err := ParseExpiration(req.ExpiresAt, req.TTL, now)
+	if err != nil {
+		return nil, resp.Error(err.Error())
+	}
+
+	if !domains.Allows(req.Domain) {
+		return nil, resp.Error(ErrDomainNotAllowed.Error())
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+	if errors.Is(err, password.ErrTooLong) {
+		return nil, resp.Error(err.Error())
+	}
+	if err != nil {
+		return nil, resp.Error("failed to hash password")
+	}
+
+	item := &batchItem{
+		url:   req.URL,
+		alias: req.Alias,
+		opts: storage.URLOptions{
+			ExpiresAt:    expiresAt,
+			RedirectType: req.RedirectType,
+			PasswordHash: passwordHash,
+			MaxClicks:    req.MaxClicks,
+			Title:        req.Title,
+			Preview:      req.Preview,
+			Domain:       domain.Normalize(req.Domain),
+		},
+	}
+	if item.alias != "" {
+		return item, resp.Response{}
+	}
+
+	strategy := req.AliasStrategy
+	if strategy == "" {
+		strategy = defaultStrategy
+	}
+
+	gen, ok := generators[strategy]
+	if !ok {
+		return nil, resp.Error("unknown alias strategy")
+	}
+
+	item.generator = gen
+	item.attempt = newAliasAttempt(req.AliasLength)
+
+	return item, resp.Response{}
+}
@@ -0,0 +1,213 @@
+package save_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/handlers/url/save/mocks"
+	mocks2 "url-shortener/internal/http-server/middleware/authenticator/mocks"
+	"url-shortener/internal/lib/alias"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestBatchHandler(t *testing.T) {
+	const (
+		userId = int64(42)
+		seed   = int64(1)
+	)
+
+	// same seed produces the aliases the handler will generate
+	expected := seededRandom(seed)
+	generated := make([]string, 2)
+	for i := range generated {
+		a, err := expected.Generate(context.Background(), 6)
+		require.NoError(t, err)
+		generated[i] = a
+	}
+
+	type saveCall struct {
+		urls    []storage.URLToSave
+		results []storage.SaveResult
+		err     error
+	}
+
+	cases := []struct {
+		name     string
+		body     string
+		calls    []saveCall
+		respCode int
+		results  []save.Response
+	}{
+		{
+			name: "Partial failure",
+			body: `[
+				{"url": "https://google.com", "alias": "google"},
+				{"url": "some invalid URL"},
+				{"url": "https://ya.ru"},
+				{"url": "https://go.dev", "alias": "taken"}
+			]`,
+			calls: []saveCall{
+				{
+					urls: []storage.URLToSave{
+						{URL: "https://google.com", Alias: "google"},
+						{URL: "https://ya.ru", Alias: generated[0]},
+						{URL: "https://go.dev", Alias: "taken"},
+					},
+					results: []storage.SaveResult{
+						{ID: 1},
+						{ID: 2},
+						{Err: storage.ErrURLExists},
+					},
+				},
+			},
+			respCode: http.StatusOK,
+			results: []save.Response{
+				{Response: resp.OK(), Alias: "google"},
+				{Response: resp.Error("field URL is not a valid URL")},
+				{Response: resp.OK(), Alias: generated[0]},
+				{Response: resp.Error("alias already exists")},
+			},
+		},
+		{
+			name: "Generated alias collision is retried",
+			body: `[{"url": "https://google.com"}]`,
+			calls: []saveCall{
+				{
+					urls:    []storage.URLToSave{{URL: "https://google.com", Alias: generated[0]}},
+					results: []storage.SaveResult{{Err: storage.ErrURLExists}},
+				},
+				{
+					urls:    []storage.URLToSave{{URL: "https://google.com", Alias: generated[1]}},
+					results: []storage.SaveResult{{ID: 1}},
+				},
+			},
+			respCode: http.StatusOK,
+			results: []save.Response{
+				{Response: resp.OK(), Alias: generated[1]},
+			},
+		},
+		{
+			name: "Storage failure",
+			body: `[{"url": "https://google.com", "alias": "google"}, {"url": "https://ya.ru", "ttl": "-1h"}]`,
+			calls: []saveCall{
+				{
+					urls: []storage.URLToSave{{URL: "https://google.com", Alias: "google"}},
+					err:  errors.New("database is locked"),
+				},
+			},
+			respCode: http.StatusOK,
+			results: []save.Response{
+				{Response: resp.Error("failed to save url")},
+				{Response: resp.Error(save.ErrInvalidTTL.Error())},
+			},
+		},
+		{
+			name: "Invalid aliases",
+			body: `[
+				{"url": "https://google.com", "alias": "google+"},
+				{"url": "https://ya.ru", "alias": "metrics"},
+				{"url": "https://go.dev", "alias": "go_dev"}
+			]`,
+			calls: []saveCall{
+				{
+					urls:    []storage.URLToSave{{URL: "https://go.dev", Alias: "go_dev"}},
+					results: []storage.SaveResult{{ID: 1}},
+				},
+			},
+			respCode: http.StatusOK,
+			results: []save.Response{
+				{Response: resp.Error(alias.ErrInvalid.Error())},
+				{Response: resp.Error(alias.ErrReserved.Error())},
+				{Response: resp.OK(), Alias: "go_dev"},
+			},
+		},
+		{
+			name: "Domains",
+			body: `[
+				{"url": "https://google.com", "alias": "google", "domain": "Go.Example"},
+				{"url": "https://ya.ru", "alias": "google"},
+				{"url": "https://go.dev", "alias": "google", "domain": "other.example"}
+			]`,
+			calls: []saveCall{
+				{
+					urls: []storage.URLToSave{
+						{URL: "https://google.com", Alias: "google", Opts: storage.URLOptions{Domain: "go.example"}},
+						{URL: "https://ya.ru", Alias: "google"},
+					},
+					results: []storage.SaveResult{{ID: 1}, {ID: 2}},
+				},
+			},
+			respCode: http.StatusOK,
+			results: []save.Response{
+				{Response: resp.OK(), Alias: "google"},
+				{Response: resp.OK(), Alias: "google"},
+				{Response: resp.Error(save.ErrDomainNotAllowed.Error())},
+			},
+		},
+		{
+			name:     "Empty batch",
+			body:     `[]`,
+			respCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Not an array",
+			body:     `{"url": "https://google.com"}`,
+			respCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Too many passwords",
+			body:     "[" + strings.Repeat(`{"url": "https://google.com", "password": "secret"},`, save.MaxBatchPasswords) + `{"url": "https://google.com", "password": "secret"}]`,
+			respCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlSaverMock := mocks.NewURLBatchSaver(t)
+			for _, call := range tc.calls {
+				urlSaverMock.On("SaveURLs", mock.Anything, userId, call.urls).
+					Return(call.results, call.err).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Post("/url/batch", save.NewBatch(slogdiscard.NewDiscardLogger(), urlSaverMock, aliasOptions(seed), domain.NewAllowlist([]string{"go.example"})))
+
+			req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			var res save.BatchResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+			if tc.respCode != http.StatusOK {
+				require.Equal(t, resp.StatusError, res.Status)
+				return
+			}
+
+			require.Equal(t, resp.StatusOK, res.Status)
+			require.Equal(t, tc.results, res.Results)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// URLBatchSaver is an autogenerated mock type for the URLBatchSaver type
+type URLBatchSaver struct {
+	mock.Mock
+}
+
+// SaveURLs provides a mock function with given fields: ctx, userID, urls
+func (_m *URLBatchSaver) SaveURLs(ctx context.Context, userID int64, urls []storage.URLToSave) ([]storage.SaveResult, error) {
+	ret := _m.Called(ctx, userID, urls)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveURLs")
+	}
+
+	var r0 []storage.SaveResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []storage.URLToSave) ([]storage.SaveResult, error)); ok {
+		return rf(ctx, userID, urls)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []storage.URLToSave) []storage.SaveResult); ok {
+		r0 = rf(ctx, userID, urls)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.SaveResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, []storage.URLToSave) error); ok {
+		r1 = rf(ctx, userID, urls)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLBatchSaver creates a new instance of URLBatchSaver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLBatchSaver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLBatchSaver {
+	mock := &URLBatchSaver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
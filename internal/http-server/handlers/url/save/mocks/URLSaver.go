@@ -2,16 +2,22 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
 
 // URLSaver is an autogenerated mock type for the URLSaver type
 type URLSaver struct {
 	mock.Mock
 }
 
-// SaveURL provides a mock function with given fields: urlToSave, alias
-func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
-	ret := _m.Called(urlToSave, alias)
+// SaveURL provides a mock function with given fields: ctx, urlToSave, alias, userID, opts
+func (_m *URLSaver) SaveURL(ctx context.Context, urlToSave string, alias string, userID int64, opts storage.URLOptions) (int64, error) {
+	ret := _m.Called(ctx, urlToSave, alias, userID, opts)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SaveURL")
@@ -19,17 +25,17 @@ func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
 
 	var r0 int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (int64, error)); ok {
-		return rf(urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int64, storage.URLOptions) (int64, error)); ok {
+		return rf(ctx, urlToSave, alias, userID, opts)
 	}
-	if rf, ok := ret.Get(0).(func(string, string) int64); ok {
-		r0 = rf(urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int64, storage.URLOptions) int64); ok {
+		r0 = rf(ctx, urlToSave, alias, userID, opts)
 	} else {
 		r0 = ret.Get(0).(int64)
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(urlToSave, alias)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int64, storage.URLOptions) error); ok {
+		r1 = rf(ctx, urlToSave, alias, userID, opts)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -1,23 +1,59 @@
 package save
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
 	"log/slog"
 	"net/http"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	"url-shortener/internal/lib/alias"
 	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
 	"url-shortener/internal/lib/logger/sl"
-	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/lib/tracing"
 	"url-shortener/internal/storage"
 )
 
 type Request struct {
 	URL   string `json:"url" validate:"required,url"`
 	Alias string `json:"alias,omitempty"`
+	// ExpiresAt and TTL (e.g. "72h") are mutually exclusive ways to limit url lifetime.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+	// AliasStrategy and AliasLength override configured generation of alias
+	// when it's not given.
+	AliasStrategy string `json:"alias_strategy,omitempty"`
+	AliasLength   int    `json:"alias_length,omitempty" validate:"omitempty,min=1,max=16"`
+	// RedirectType is HTTP status of redirects, the configured one if not set.
+	RedirectType int `json:"redirect_type,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+	// Password protects url, it's asked for before redirecting.
+	Password string `json:"password,omitempty"`
+	// MaxClicks limits how many times url redirects, 1 makes it one-time.
+	MaxClicks int64 `json:"max_clicks,omitempty" validate:"omitempty,min=1"`
+	// Title and Preview make redirects show preview page of url with title.
+	Title   string `json:"title,omitempty" validate:"max=200"`
+	Preview bool   `json:"preview,omitempty"`
+	// Domain is short domain of url, the primary one if not set.
+	// It must be one of configured domains.
+	Domain string `json:"domain,omitempty"`
+}
+
+// LogValue hides password of request from logs.
+func (r Request) LogValue() slog.Value {
+	// request has no LogValue method, so it's logged as is
+	type request Request
+	if r.Password != "" {
+		r.Password = "***"
+	}
+
+	return slog.AnyValue(request(r))
 }
 
 type Response struct {
@@ -25,15 +61,43 @@ type Response struct {
 	Alias string `json:"alias,omitempty"`
 }
 
-// TODO: move to config if needed
-const aliasLength = 6
+const (
+	// aliasAttempts is how many generated aliases are tried before giving up.
+	aliasAttempts = 5
+	// aliasGrowthCollisions is how many collisions within one save make
+	// generated aliases one char longer.
+	aliasGrowthCollisions = 2
+	maxAliasLength        = 16
+)
+
+var (
+	ErrNoFreeAlias = errors.New("failed to generate free alias")
+
+	ErrExpirationConflict = errors.New("only one of expires_at and ttl can be set")
+	ErrInvalidTTL         = errors.New("field ttl must be a positive duration")
+	ErrExpiresInPast      = errors.New("field expires_at must be in the future")
+	ErrDomainNotAllowed   = errors.New("domain is not allowed")
+)
 
 //go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLSaver
 type URLSaver interface {
-	SaveURL(urlToSave string, alias string) (int64, error)
+	SaveURL(ctx context.Context, urlToSave string, alias string, userID int64, opts storage.URLOptions) (int64, error)
+}
+
+// AliasOptions configure generation of aliases that are not given in request.
+type AliasOptions struct {
+	// Generators maps strategy names to generators.
+	Generators map[string]alias.Generator
+	// Strategy and Length are used unless overridden by request.
+	Strategy string
+	Length   int
 }
 
-func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
+// New returns handler saving url. Urls are saved on the primary domain
+// unless request sets one of domains.
+func New(log *slog.Logger, urlSaver URLSaver, aliasOpts AliasOptions, domains domain.Allowlist) http.HandlerFunc {
+	generators := newAliasGenerators(aliasOpts)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.save.New"
 
@@ -42,15 +106,22 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		token, claims, err := jwtauth.FromContext(r.Context())
-		if err != nil {
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		userId, ok := authenticator.UserIdFromContext(r.Context())
+		if !ok {
+			log.Info("failed to get userId from context")
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
 			return
 		}
-		log.Info(fmt.Sprintf("token: %s, claims: %v", token, claims))
 
 		var req Request
 
-		err = render.DecodeJSON(r.Body, &req)
+		err := render.DecodeJSON(r.Body, &req)
 		if err != nil {
 			log.Error("failed to decode request body", sl.Err(err))
 
@@ -72,20 +143,94 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 			return
 		}
 
-		// TODO: implement check if new random alias is already exists in database
-		alias := req.Alias
-		if alias == "" {
-			alias = random.NewRandomString(aliasLength)
+		if req.Alias != "" {
+			if err = alias.Validate(req.Alias); err != nil {
+				log.Info("invalid alias", sl.Err(err))
+
+				render.JSON(w, r, resp.Error(err.Error()))
+
+				return
+			}
+		}
+
+		expiresAt, err := ParseExpiration(req.ExpiresAt, req.TTL, time.Now())
+		if err != nil {
+			log.Info("invalid expiration", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
 		}
 
-		id, err := urlSaver.SaveURL(req.URL, alias)
-		if errors.Is(err, storage.ErrURLExists) {
-			log.Info("url already exists", slog.String("url", req.URL))
+		if !domains.Allows(req.Domain) {
+			log.Info("domain is not allowed", slog.String("domain", req.Domain))
 
-			render.JSON(w, r, resp.Error("url already exists"))
+			render.JSON(w, r, resp.Error(ErrDomainNotAllowed.Error()))
 
 			return
 		}
+
+		passwordHash, err := hashPassword(req.Password)
+		if errors.Is(err, password.ErrTooLong) {
+			log.Info("invalid password", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to hash password", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to save url"))
+
+			return
+		}
+
+		opts := storage.URLOptions{
+			ExpiresAt:    expiresAt,
+			RedirectType: req.RedirectType,
+			PasswordHash: passwordHash,
+			MaxClicks:    req.MaxClicks,
+			Title:        req.Title,
+			Preview:      req.Preview,
+			Domain:       domain.Normalize(req.Domain),
+		}
+
+		var id int64
+		alias := req.Alias
+		if alias != "" {
+			id, err = urlSaver.SaveURL(ctx, req.URL, alias, userId, opts)
+			if errors.Is(err, storage.ErrURLExists) {
+				log.Info("alias already exists", slog.String("alias", alias))
+
+				render.JSON(w, r, resp.Error("alias already exists"))
+
+				return
+			}
+		} else {
+			strategy := req.AliasStrategy
+			if strategy == "" {
+				strategy = aliasOpts.Strategy
+			}
+
+			gen, ok := generators[strategy]
+			if !ok {
+				log.Info("unknown alias strategy", slog.String("strategy", strategy))
+
+				render.JSON(w, r, resp.Error("unknown alias strategy"))
+
+				return
+			}
+
+			alias, id, err = gen.save(ctx, urlSaver, req.URL, userId, opts, req.AliasLength)
+			if errors.Is(err, ErrNoFreeAlias) {
+				log.Error("failed to generate alias", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("failed to generate alias"))
+
+				return
+			}
+		}
 		if err != nil {
 			log.Error("failed to save url", sl.Err(err))
 
@@ -96,10 +241,141 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 
 		log.Info("url added", slog.Int64("id", id))
 
+		metrics.LinksCreated.Inc()
+
 		responseOK(w, r, alias)
 	}
 }
 
+// aliasGenerator saves urls under generated aliases. Collisions are retried,
+// and frequent ones make following aliases longer.
+type aliasGenerator struct {
+	generator alias.Generator
+	// length is shared by requests that don't set alias length.
+	length atomic.Int64
+}
+
+func newAliasGenerators(aliasOpts AliasOptions) map[string]*aliasGenerator {
+	generators := make(map[string]*aliasGenerator, len(aliasOpts.Generators))
+	for name, gen := range aliasOpts.Generators {
+		generators[name] = &aliasGenerator{generator: gen}
+		generators[name].length.Store(int64(aliasOpts.Length))
+	}
+
+	return generators
+}
+
+// aliasAttempt tracks generation of alias for one url.
+type aliasAttempt struct {
+	length int
+	// shared is set if length follows length of the generator.
+	shared     bool
+	collisions int
+}
+
+// newAliasAttempt returns attempt to generate alias of given length,
+// or of shared length if it's zero.
+func newAliasAttempt(length int) aliasAttempt {
+	return aliasAttempt{
+		length: length,
+		shared: length == 0,
+	}
+}
+
+// generate returns the next alias to try. Generator errors, like failure
+// to take the next id of sequence, are returned as is.
+func (g *aliasGenerator) generate(ctx context.Context, a *aliasAttempt) (string, error) {
+	if a.shared {
+		a.length = int(g.length.Load())
+	}
+
+	return g.generator.Generate(ctx, a.length)
+}
+
+// collided records that the last generated alias was taken.
+func (g *aliasGenerator) collided(a *aliasAttempt) {
+	a.collisions++
+	if a.collisions < aliasGrowthCollisions || a.length >= maxAliasLength {
+		return
+	}
+
+	if a.shared {
+		// another request may have grown it already
+		g.length.CompareAndSwap(int64(a.length), int64(a.length+1))
+	} else {
+		a.length++
+	}
+	a.collisions = 0
+}
+
+// save saves url under generated alias of given length, or of shared
+// length if it's zero.
+func (g *aliasGenerator) save(
+	ctx context.Context,
+	urlSaver URLSaver,
+	urlToSave string,
+	userID int64,
+	opts storage.URLOptions,
+	length int,
+) (string, int64, error) {
+	attempt := newAliasAttempt(length)
+	for i := 0; i < aliasAttempts; i++ {
+		a, err := g.generate(ctx, &attempt)
+		if err != nil {
+			return "", 0, err
+		}
+
+		id, err := urlSaver.SaveURL(ctx, urlToSave, a, userID, opts)
+		if !errors.Is(err, storage.ErrURLExists) {
+			return a, id, err
+		}
+
+		g.collided(&attempt)
+	}
+
+	return "", 0, ErrNoFreeAlias
+}
+
+// hashPassword returns hash of password, or empty string if url has none.
+func hashPassword(pw string) (string, error) {
+	if pw == "" {
+		return "", nil
+	}
+
+	return password.Hash(pw)
+}
+
+// ParseExpiration returns the moment url expires given either absolute
+// expiresAt or ttl relative to now. It returns nil if neither is set.
+func ParseExpiration(expiresAt *time.Time, ttl string, now time.Time) (*time.Time, error) {
+	if expiresAt != nil && ttl != "" {
+		return nil, ErrExpirationConflict
+	}
+
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidTTL
+		}
+
+		t := now.Add(d).UTC()
+
+		return &t, nil
+	}
+
+	if expiresAt != nil {
+		if !expiresAt.After(now) {
+			return nil, ErrExpiresInPast
+		}
+
+		t := expiresAt.UTC()
+
+		return &t, nil
+	}
+
+	return nil, nil
+}
+
 func responseOK(w http.ResponseWriter, r *http.Request, alias string) {
 	render.JSON(w, r, Response{
 		Response: resp.OK(),
@@ -2,26 +2,42 @@ package save_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"url-shortener/internal/http-server/handlers/url/save"
 	"url-shortener/internal/http-server/handlers/url/save/mocks"
+	mocks2 "url-shortener/internal/http-server/middleware/authenticator/mocks"
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/domain"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/storage"
 )
 
 func TestSaveHandler(t *testing.T) {
+	const userId = int64(42)
+
 	cases := []struct {
-		name      string
-		alias     string
-		url       string
-		respError string
-		mockError error
+		name         string
+		alias        string
+		url          string
+		extra        string
+		redirectType int
+		password     string
+		maxClicks    int64
+		domain       string
+		respError    string
+		mockError    error
 	}{
 		{
 			name:  "Success",
@@ -33,6 +49,29 @@ func TestSaveHandler(t *testing.T) {
 			alias: "",
 			url:   "https://google.com",
 		},
+		{
+			name:  "Alias with dash",
+			alias: "brave-otter-42",
+			url:   "https://google.com",
+		},
+		{
+			name:      "Alias of preview page",
+			alias:     "test_alias+",
+			url:       "https://google.com",
+			respError: alias.ErrInvalid.Error(),
+		},
+		{
+			name:      "Alias with slash",
+			alias:     "a/b",
+			url:       "https://google.com",
+			respError: alias.ErrInvalid.Error(),
+		},
+		{
+			name:      "Reserved alias",
+			alias:     "Healthz",
+			url:       "https://google.com",
+			respError: alias.ErrReserved.Error(),
+		},
 		{
 			name:      "Empty URL",
 			url:       "",
@@ -45,6 +84,105 @@ func TestSaveHandler(t *testing.T) {
 			alias:     "some_alias",
 			respError: "field URL is not a valid URL",
 		},
+		{
+			name:  "With TTL",
+			alias: "test_alias",
+			url:   "https://google.com",
+			extra: `, "ttl": "24h"`,
+		},
+		{
+			name:  "With expires_at",
+			alias: "test_alias",
+			url:   "https://google.com",
+			extra: `, "expires_at": "2999-01-01T00:00:00Z"`,
+		},
+		{
+			name:      "Expires in the past",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			extra:     `, "expires_at": "2000-01-01T00:00:00Z"`,
+			respError: "field expires_at must be in the future",
+		},
+		{
+			name:      "Both TTL and expires_at",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			extra:     `, "ttl": "24h", "expires_at": "2999-01-01T00:00:00Z"`,
+			respError: "only one of expires_at and ttl can be set",
+		},
+		{
+			name:      "Invalid TTL",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			extra:     `, "ttl": "-1h"`,
+			respError: "field ttl must be a positive duration",
+		},
+		{
+			name:         "With redirect_type",
+			alias:        "test_alias",
+			url:          "https://google.com",
+			redirectType: 308,
+		},
+		{
+			name:         "Invalid redirect_type",
+			alias:        "test_alias",
+			url:          "https://google.com",
+			redirectType: 303,
+			respError:    "field RedirectType must be one of 301 302 307 308",
+		},
+		{
+			name:     "With password",
+			alias:    "test_alias",
+			url:      "https://google.com",
+			password: "secret",
+		},
+		{
+			name:      "Too long password",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			password:  strings.Repeat("a", 73),
+			respError: "password must be at most 72 bytes long",
+		},
+		{
+			name:      "One-time",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			maxClicks: 1,
+		},
+		{
+			name:      "Invalid max_clicks",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			maxClicks: -1,
+			respError: "field MaxClicks is not valid",
+		},
+		{
+			name:      "Too long title",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			extra:     `, "preview": true, "title": "` + strings.Repeat("a", 201) + `"`,
+			respError: "field Title is not valid",
+		},
+		{
+			name:   "On custom domain",
+			alias:  "test_alias",
+			url:    "https://google.com",
+			domain: "go.example",
+		},
+		{
+			name:      "Domain not allowed",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			domain:    "other.example",
+			respError: "domain is not allowed",
+		},
+		{
+			name:      "Alias exists",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			respError: "alias already exists",
+			mockError: storage.ErrURLExists,
+		},
 		{
 			name:      "SaveURL Error",
 			alias:     "test_alias",
@@ -63,19 +201,46 @@ func TestSaveHandler(t *testing.T) {
 			urlSaverMock := mocks.NewURLSaver(t)
 
 			if tc.respError == "" || tc.mockError != nil {
-				urlSaverMock.On("SaveURL", tc.url, mock.AnythingOfType("string")).
+				urlSaverMock.On("SaveURL", mock.Anything, tc.url,
+					mock.AnythingOfType("string"),
+					userId,
+					mock.MatchedBy(func(opts storage.URLOptions) bool {
+						// expiration is passed only when requested
+						return (opts.ExpiresAt != nil) == (tc.extra != "") &&
+							opts.RedirectType == tc.redirectType &&
+							opts.MaxClicks == tc.maxClicks &&
+							opts.Domain == tc.domain &&
+							(tc.password == "" && opts.PasswordHash == "" || password.Matches(opts.PasswordHash, tc.password))
+					}),
+				).
 					Return(int64(1), tc.mockError).
 					Once()
 			}
-			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock)
 
-			input := fmt.Sprintf(`{"url": "%s", "alias": "%s"}`, tc.url, tc.alias)
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Post("/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, aliasOptions(1), domain.NewAllowlist([]string{"go.example"})))
+
+			extra := tc.extra
+			if tc.redirectType != 0 {
+				extra += fmt.Sprintf(`, "redirect_type": %d`, tc.redirectType)
+			}
+			if tc.password != "" {
+				extra += fmt.Sprintf(`, "password": "%s"`, tc.password)
+			}
+			if tc.maxClicks != 0 {
+				extra += fmt.Sprintf(`, "max_clicks": %d`, tc.maxClicks)
+			}
+			if tc.domain != "" {
+				extra += fmt.Sprintf(`, "domain": "%s"`, tc.domain)
+			}
+			input := fmt.Sprintf(`{"url": "%s", "alias": "%s"%s}`, tc.url, tc.alias, extra)
 
 			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(input)))
 			require.NoError(t, err)
 
 			rr := httptest.NewRecorder()
-			handler.ServeHTTP(rr, req)
+			r.ServeHTTP(rr, req)
 
 			require.Equal(t, rr.Code, http.StatusOK)
 
@@ -90,3 +255,226 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveHandler_RandomAliasCollisions(t *testing.T) {
+	const (
+		userId = int64(42)
+		seed   = int64(1)
+	)
+
+	cases := []struct {
+		name       string
+		collisions int
+		// lengths of generated aliases, the last one is saved unless respError is set
+		lengths   []int
+		respError string
+	}{
+		{
+			name:    "No collisions",
+			lengths: []int{6},
+		},
+		{
+			name:       "Single collision is retried",
+			collisions: 1,
+			lengths:    []int{6, 6},
+		},
+		{
+			name:       "Frequent collisions grow alias",
+			collisions: 3,
+			lengths:    []int{6, 6, 7, 7},
+		},
+		{
+			name:       "Attempts exhausted",
+			collisions: 5,
+			lengths:    []int{6, 6, 7, 7, 8},
+			respError:  "failed to generate alias",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// same seed produces the aliases the handler will try
+			expected := seededRandom(seed)
+			var aliases []string
+			for _, length := range tc.lengths {
+				a, err := expected.Generate(context.Background(), length)
+				require.NoError(t, err)
+				aliases = append(aliases, a)
+			}
+
+			urlSaverMock := mocks.NewURLSaver(t)
+			for i, alias := range aliases {
+				var err error
+				if i < tc.collisions {
+					err = storage.ErrURLExists
+				}
+
+				urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", alias, userId, storage.URLOptions{}).
+					Return(int64(1), err).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Post("/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, aliasOptions(seed), nil))
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(`{"url": "https://google.com"}`)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Error)
+			if tc.respError == "" {
+				require.Equal(t, aliases[len(aliases)-1], resp.Alias)
+			}
+		})
+	}
+}
+
+func TestSaveHandler_GrownAliasLengthIsKept(t *testing.T) {
+	const userId = int64(42)
+
+	urlSaverMock := mocks.NewURLSaver(t)
+	urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", mock.MatchedBy(func(alias string) bool {
+		return len(alias) == 6
+	}), userId, storage.URLOptions{}).
+		Return(int64(0), storage.ErrURLExists).
+		Twice()
+	urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", mock.MatchedBy(func(alias string) bool {
+		return len(alias) == 7
+	}), userId, storage.URLOptions{}).
+		Return(int64(1), nil).
+		Twice()
+
+	r := chi.NewRouter()
+	r.Use(mocks2.UserIdAdder(userId))
+	r.Post("/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, aliasOptions(1), nil))
+
+	// the second request starts with the grown length
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(`{"url": "https://google.com"}`)))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		var resp save.Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Empty(t, resp.Error)
+		require.Len(t, resp.Alias, 7)
+	}
+}
+
+func TestSaveHandler_AliasOptions(t *testing.T) {
+	const userId = int64(42)
+
+	cases := []struct {
+		name      string
+		extra     string
+		alias     string
+		respError string
+	}{
+		{
+			name:  "Configured strategy and length",
+			alias: "fixed-6",
+		},
+		{
+			name:  "Strategy from request",
+			extra: `, "alias_strategy": "words"`,
+			alias: "words-6",
+		},
+		{
+			name:  "Length from request",
+			extra: `, "alias_length": 9`,
+			alias: "fixed-9",
+		},
+		{
+			name:      "Unknown strategy",
+			extra:     `, "alias_strategy": "unknown"`,
+			respError: "unknown alias strategy",
+		},
+		{
+			name:      "Invalid length",
+			extra:     `, "alias_length": 100`,
+			respError: "field AliasLength is not valid",
+		},
+		{
+			name:      "Generator error",
+			extra:     `, "alias_strategy": "broken"`,
+			respError: "failed to save url",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlSaverMock := mocks.NewURLSaver(t)
+			if tc.respError == "" {
+				urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", tc.alias, userId, storage.URLOptions{}).
+					Return(int64(1), nil).
+					Once()
+			}
+
+			named := func(name string) alias.Generator {
+				return alias.GeneratorFunc(func(_ context.Context, length int) (string, error) {
+					return fmt.Sprintf("%s-%d", name, length), nil
+				})
+			}
+
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Post("/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, save.AliasOptions{
+				Generators: map[string]alias.Generator{
+					"fixed": named("fixed"),
+					"words": named("words"),
+					"broken": alias.GeneratorFunc(func(context.Context, int) (string, error) {
+						return "", errors.New("unexpected error")
+					}),
+				},
+				Strategy: "fixed",
+				Length:   6,
+			}, nil))
+
+			input := fmt.Sprintf(`{"url": "https://google.com"%s}`, tc.extra)
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(input)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Error)
+			require.Equal(t, tc.alias, resp.Alias)
+		})
+	}
+}
+
+// seededRandom returns random alias generator with deterministic output.
+func seededRandom(seed int64) *alias.Random {
+	return alias.NewRandomFrom(rand.New(rand.NewSource(seed)))
+}
+
+// aliasOptions returns options generating random aliases from seeded source.
+func aliasOptions(seed int64) save.AliasOptions {
+	return save.AliasOptions{
+		Generators: map[string]alias.Generator{
+			alias.StrategyRandom: seededRandom(seed),
+		},
+		Strategy: alias.StrategyRandom,
+		Length:   6,
+	}
+}
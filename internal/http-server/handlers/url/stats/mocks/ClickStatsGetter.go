@@ -0,0 +1,88 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+
+	time "time"
+)
+
+// ClickStatsGetter is an autogenerated mock type for the ClickStatsGetter type
+type ClickStatsGetter struct {
+	mock.Mock
+}
+
+// GetClickStats provides a mock function with given fields: ctx, domain, alias, from, to, bucket
+func (_m *ClickStatsGetter) GetClickStats(ctx context.Context, domain string, alias string, from time.Time, to time.Time, bucket time.Duration) (storage.ClickStats, error) {
+	ret := _m.Called(ctx, domain, alias, from, to, bucket)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetClickStats")
+	}
+
+	var r0 storage.ClickStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time, time.Duration) (storage.ClickStats, error)); ok {
+		return rf(ctx, domain, alias, from, to, bucket)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time, time.Duration) storage.ClickStats); ok {
+		r0 = rf(ctx, domain, alias, from, to, bucket)
+	} else {
+		r0 = ret.Get(0).(storage.ClickStats)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, time.Time, time.Duration) error); ok {
+		r1 = rf(ctx, domain, alias, from, to, bucket)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetURLOwner provides a mock function with given fields: ctx, domain, alias
+func (_m *ClickStatsGetter) GetURLOwner(ctx context.Context, domain string, alias string) (int64, error) {
+	ret := _m.Called(ctx, domain, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetURLOwner")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (int64, error)); ok {
+		return rf(ctx, domain, alias)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int64); ok {
+		r0 = rf(ctx, domain, alias)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, domain, alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewClickStatsGetter creates a new instance of ClickStatsGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewClickStatsGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ClickStatsGetter {
+	mock := &ClickStatsGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
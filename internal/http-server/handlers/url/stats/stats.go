@@ -0,0 +1,236 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	"url-shortener/internal/lib/access"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+)
+
+type Bucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+type Response struct {
+	resp.Response
+	Alias    string    `json:"alias,omitempty"`
+	Total    int64     `json:"total"`
+	Interval string    `json:"interval,omitempty"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Buckets  []Bucket  `json:"buckets,omitempty"`
+}
+
+const (
+	intervalHour = "hour"
+	intervalDay  = "day"
+
+	maxBuckets = 1000
+)
+
+var intervals = map[string]struct {
+	size          time.Duration
+	defaultPeriod time.Duration
+}{
+	intervalHour: {size: time.Hour, defaultPeriod: 24 * time.Hour},
+	intervalDay:  {size: 24 * time.Hour, defaultPeriod: 30 * 24 * time.Hour},
+}
+
+// ClickStatsGetter is an interface for getting click statistics of url.
+// GetURLOwner is used to show statistics only to owners and admins.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=ClickStatsGetter
+type ClickStatsGetter interface {
+	GetURLOwner(ctx context.Context, domain, alias string) (int64, error)
+	GetClickStats(ctx context.Context, domain, alias string, from, to time.Time, bucket time.Duration) (storage.ClickStats, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=IsAdminChecker
+type IsAdminChecker interface {
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+// New returns handler with click statistics of url.
+//
+// Query parameters:
+//   - interval: hour or day (default), size of a bucket
+//   - from, to: RFC 3339 time range, defaults to the last day for hour
+//     interval and to the last 30 days for day interval
+//   - domain: domain of url, the primary one if empty
+func New(log *slog.Logger, statsGetter ClickStatsGetter, isAdminChecker IsAdminChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.stats.New"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		userId, ok := authenticator.UserIdFromContext(r.Context())
+		if !ok {
+			log.Info("failed to get userId from context")
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		query := r.URL.Query()
+		urlDomain := domain.Normalize(query.Get("domain"))
+
+		interval := query.Get("interval")
+		if interval == "" {
+			interval = intervalDay
+		}
+
+		params, ok := intervals[interval]
+		if !ok {
+			log.Info("invalid interval", slog.String("interval", interval))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("field interval must be one of: hour, day"))
+
+			return
+		}
+
+		to := time.Now().UTC()
+		if v := query.Get("to"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				log.Info("invalid to", sl.Err(err))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("field to is not a valid RFC 3339 time"))
+
+				return
+			}
+			to = t.UTC()
+		}
+
+		from := to.Add(-params.defaultPeriod)
+		if v := query.Get("from"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				log.Info("invalid from", sl.Err(err))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("field from is not a valid RFC 3339 time"))
+
+				return
+			}
+			from = t.UTC()
+		}
+
+		if !from.Before(to) || to.Sub(from)/params.size > maxBuckets {
+			log.Info("invalid time range", slog.Time("from", from), slog.Time("to", to))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid time range"))
+
+			return
+		}
+
+		err := access.CanManageURL(ctx, statsGetter, isAdminChecker, urlDomain, alias, userId)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", "alias", alias)
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if errors.Is(err, access.ErrForbidden) {
+			log.Info("user is neither owner nor admin", slog.Int64("user_id", userId))
+
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, resp.Error("you are not allowed to see stats of this url"))
+
+			return
+		}
+		if err != nil {
+			log.Info("failed to check access to url", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		stats, err := statsGetter.GetClickStats(ctx, urlDomain, alias, from, to, params.size)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", "alias", alias)
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get click stats", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Alias:    alias,
+			Total:    stats.Total,
+			Interval: interval,
+			From:     from,
+			To:       to,
+			Buckets:  fillBuckets(stats.Buckets, from, to, params.size),
+		})
+	}
+}
+
+// fillBuckets returns all buckets in [from, to) including empty ones,
+// so clients can draw charts without gaps.
+func fillBuckets(buckets []storage.ClickBucket, from, to time.Time, size time.Duration) []Bucket {
+	counts := make(map[int64]int64, len(buckets))
+	for _, b := range buckets {
+		counts[b.Start.Unix()] = b.Count
+	}
+
+	// buckets are aligned to unix epoch the same way as in storage
+	step := int64(size.Seconds())
+	start := from.Unix() / step * step
+
+	var res []Bucket
+	for t := start; t < to.Unix(); t += step {
+		res = append(res, Bucket{
+			Start:  time.Unix(t, 0).UTC(),
+			Clicks: counts[t],
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,161 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/stats"
+	"url-shortener/internal/http-server/handlers/url/stats/mocks"
+	mocks2 "url-shortener/internal/http-server/middleware/authenticator/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestStatsHandler(t *testing.T) {
+	const userId = int64(1)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	query := "?interval=hour&from=" + from.Format(time.RFC3339) + "&to=" + to.Format(time.RFC3339)
+
+	cases := []struct {
+		name              string
+		query             string
+		ownerId           int64
+		viaAPIKey         bool
+		shouldGetOwner    bool
+		shouldCallIsAdmin bool
+		isAdmin           bool
+		shouldGetStats    bool
+		mockStats         storage.ClickStats
+		mockError         error
+		statusCode        int
+		respError         string
+		respBuckets       []stats.Bucket
+	}{
+		{
+			name:           "Success",
+			query:          query,
+			ownerId:        userId,
+			shouldGetOwner: true,
+			shouldGetStats: true,
+			mockStats: storage.ClickStats{
+				Total: 10,
+				Buckets: []storage.ClickBucket{
+					{Start: from.Add(time.Hour), Count: 4},
+				},
+			},
+			statusCode: http.StatusOK,
+			respBuckets: []stats.Bucket{
+				{Start: from, Clicks: 0},
+				{Start: from.Add(time.Hour), Clicks: 4},
+				{Start: from.Add(2 * time.Hour), Clicks: 0},
+			},
+		},
+		{
+			name:              "User is neither owner nor admin",
+			query:             query,
+			ownerId:           int64(2),
+			shouldGetOwner:    true,
+			shouldCallIsAdmin: true,
+			statusCode:        http.StatusForbidden,
+			respError:         "you are not allowed to see stats of this url",
+		},
+		{
+			name:           "Admin's api key reads someone else's stats",
+			query:          query,
+			ownerId:        int64(2),
+			viaAPIKey:      true,
+			shouldGetOwner: true,
+			statusCode:     http.StatusForbidden,
+			respError:      "you are not allowed to see stats of this url",
+		},
+		{
+			name:       "Invalid interval",
+			query:      "?interval=week",
+			statusCode: http.StatusBadRequest,
+			respError:  "field interval must be one of: hour, day",
+		},
+		{
+			name:       "Invalid from",
+			query:      "?from=yesterday",
+			statusCode: http.StatusBadRequest,
+			respError:  "field from is not a valid RFC 3339 time",
+		},
+		{
+			name:       "Too many buckets",
+			query:      "?interval=hour&from=2020-01-01T00:00:00Z&to=2024-01-01T00:00:00Z",
+			statusCode: http.StatusBadRequest,
+			respError:  "invalid time range",
+		},
+		{
+			name:           "GetClickStats Error",
+			query:          query,
+			ownerId:        userId,
+			shouldGetOwner: true,
+			shouldGetStats: true,
+			mockError:      errors.New("unexpected error"),
+			statusCode:     http.StatusInternalServerError,
+			respError:      "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			isAdminCheckerMock := mocks.NewIsAdminChecker(t)
+			if tc.shouldCallIsAdmin {
+				isAdminCheckerMock.On("IsAdmin", mock.Anything, userId).
+					Return(tc.isAdmin, nil).
+					Once()
+			}
+
+			statsGetterMock := mocks.NewClickStatsGetter(t)
+			if tc.shouldGetOwner {
+				statsGetterMock.On("GetURLOwner", mock.Anything, "", "test_alias").
+					Return(tc.ownerId, nil).
+					Once()
+			}
+			if tc.shouldGetStats {
+				statsGetterMock.On("GetClickStats", mock.Anything, "", "test_alias", from, to, time.Hour).
+					Return(tc.mockStats, tc.mockError).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			if tc.viaAPIKey {
+				r.Use(mocks2.APIKeyAdder(userId, "urls:read"))
+			} else {
+				r.Use(mocks2.UserIdAdder(userId))
+			}
+			r.Get("/url/{alias}/stats", stats.New(slogdiscard.NewDiscardLogger(), statsGetterMock, isAdminCheckerMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/url/test_alias/stats"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.statusCode, rr.Code)
+
+			var resp stats.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Error)
+
+			if tc.respError == "" {
+				require.Equal(t, tc.mockStats.Total, resp.Total)
+				require.Equal(t, tc.respBuckets, resp.Buckets)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// URLExporter is an autogenerated mock type for the URLExporter type
+type URLExporter struct {
+	mock.Mock
+}
+
+// ExportURLs provides a mock function with given fields: ctx, filter
+func (_m *URLExporter) ExportURLs(ctx context.Context, filter storage.ExportFilter) ([]storage.URL, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportURLs")
+	}
+
+	var r0 []storage.URL
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.ExportFilter) ([]storage.URL, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, storage.ExportFilter) []storage.URL); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.URL)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, storage.ExportFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLExporter creates a new instance of URLExporter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLExporter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLExporter {
+	mock := &URLExporter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
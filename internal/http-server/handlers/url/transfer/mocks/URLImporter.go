@@ -0,0 +1,57 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// URLImporter is an autogenerated mock type for the URLImporter type
+type URLImporter struct {
+	mock.Mock
+}
+
+// ImportURLs provides a mock function with given fields: ctx, urls, onConflict
+func (_m *URLImporter) ImportURLs(ctx context.Context, urls []storage.URL, onConflict storage.ConflictPolicy) (storage.ImportStats, error) {
+	ret := _m.Called(ctx, urls, onConflict)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportURLs")
+	}
+
+	var r0 storage.ImportStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.URL, storage.ConflictPolicy) (storage.ImportStats, error)); ok {
+		return rf(ctx, urls, onConflict)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.URL, storage.ConflictPolicy) storage.ImportStats); ok {
+		r0 = rf(ctx, urls, onConflict)
+	} else {
+		r0 = ret.Get(0).(storage.ImportStats)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []storage.URL, storage.ConflictPolicy) error); ok {
+		r1 = rf(ctx, urls, onConflict)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLImporter creates a new instance of URLImporter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLImporter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLImporter {
+	mock := &URLImporter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,260 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/backup"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+)
+
+// maxImportSize limits size of imported file.
+const maxImportSize = 64 << 20
+
+type ImportResponse struct {
+	resp.Response
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// URLExporter is an interface for reading pages of all urls.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLExporter
+type URLExporter interface {
+	ExportURLs(ctx context.Context, filter storage.ExportFilter) ([]storage.URL, error)
+}
+
+// URLImporter is an interface for saving urls in bulk.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLImporter
+type URLImporter interface {
+	ImportURLs(ctx context.Context, urls []storage.URL, onConflict storage.ConflictPolicy) (storage.ImportStats, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=IsAdminChecker
+type IsAdminChecker interface {
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+// NewExport returns handler writing urls as a file. It's available to admins only.
+//
+// Query parameters:
+//   - format: csv (default) or ndjson
+//   - user_id: export urls of one user only
+func NewExport(log *slog.Logger, urlExporter URLExporter, isAdminChecker IsAdminChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.transfer.NewExport"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		if !requireAdmin(ctx, log, w, r, isAdminChecker) {
+			return
+		}
+
+		format, ok := parseFormat(log, w, r)
+		if !ok {
+			return
+		}
+
+		var userID *int64
+		if v := r.URL.Query().Get("user_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				log.Info("invalid user_id", sl.Err(err))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid user_id"))
+
+				return
+			}
+			userID = &id
+		}
+
+		w.Header().Set("Content-Type", backup.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="urls.%s"`, format))
+
+		written, err := backup.Export(ctx, urlExporter, userID, format, w)
+		if err != nil {
+			// the file is partly sent, status can't be changed
+			log.Error("failed to export urls", sl.Err(err), slog.Int("written", written))
+
+			return
+		}
+
+		log.Info("urls exported", slog.Int("count", written))
+	}
+}
+
+// NewImport returns handler saving urls from a file in request body.
+// It's available to admins only.
+//
+// Query parameters:
+//   - format: csv (default) or ndjson
+//   - on_conflict: fail (default), skip or overwrite urls with taken aliases
+//   - user_id: owner of urls that have none in the file, defaults to the admin
+func NewImport(log *slog.Logger, urlImporter URLImporter, isAdminChecker IsAdminChecker, domains domain.Allowlist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.transfer.NewImport"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		if !requireAdmin(ctx, log, w, r, isAdminChecker) {
+			return
+		}
+
+		format, ok := parseFormat(log, w, r)
+		if !ok {
+			return
+		}
+
+		onConflict := backup.DefaultConflictPolicy
+		if v := r.URL.Query().Get("on_conflict"); v != "" {
+			policy, err := backup.ParseConflictPolicy(v)
+			if err != nil {
+				log.Info("invalid on_conflict", sl.Err(err))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("on_conflict must be one of skip, overwrite or fail"))
+
+				return
+			}
+			onConflict = policy
+		}
+
+		ownerID, _ := authenticator.UserIdFromContext(r.Context())
+		if v := r.URL.Query().Get("user_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				log.Info("invalid user_id", sl.Err(err))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid user_id"))
+
+				return
+			}
+			ownerID = id
+		}
+
+		urls, err := backup.Decode(http.MaxBytesReader(w, r.Body, maxImportSize), format, ownerID, domains, time.Now())
+		if err != nil {
+			log.Info("failed to decode urls", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode file"))
+
+			return
+		}
+
+		stats, err := urlImporter.ImportURLs(ctx, urls, onConflict)
+		if errors.Is(err, storage.ErrURLExists) {
+			log.Info("alias already exists", sl.Err(err))
+
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("alias already exists"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to import urls", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to import urls"))
+
+			return
+		}
+
+		log.Info("urls imported",
+			slog.Int("created", stats.Created),
+			slog.Int("updated", stats.Updated),
+			slog.Int("skipped", stats.Skipped),
+		)
+
+		render.JSON(w, r, ImportResponse{
+			Response: resp.OK(),
+			Created:  stats.Created,
+			Updated:  stats.Updated,
+			Skipped:  stats.Skipped,
+		})
+	}
+}
+
+// requireAdmin responds with error and returns false unless user is admin.
+func requireAdmin(
+	ctx context.Context,
+	log *slog.Logger,
+	w http.ResponseWriter,
+	r *http.Request,
+	isAdminChecker IsAdminChecker,
+) bool {
+	userId, ok := authenticator.UserIdFromContext(r.Context())
+	if !ok {
+		log.Info("failed to get userId from context")
+
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, resp.Error("internal error"))
+
+		return false
+	}
+
+	isAdmin, err := isAdminChecker.IsAdmin(ctx, userId)
+	if err != nil {
+		log.Error("failed to check if user is admin", sl.Err(err))
+
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, resp.Error("internal error"))
+
+		return false
+	}
+
+	if !isAdmin {
+		log.Info("user is not admin", slog.Int64("user_id", userId))
+
+		render.Status(r, http.StatusForbidden)
+		render.JSON(w, r, resp.Error("only admins are allowed to transfer urls"))
+
+		return false
+	}
+
+	return true
+}
+
+func parseFormat(log *slog.Logger, w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		return backup.FormatCSV, true
+	case backup.FormatCSV, backup.FormatNDJSON:
+		return format, true
+	default:
+		log.Info("unknown format", slog.String("format", format))
+
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("format must be csv or ndjson"))
+
+		return "", false
+	}
+}
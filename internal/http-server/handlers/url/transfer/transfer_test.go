@@ -0,0 +1,276 @@
+package transfer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/transfer"
+	"url-shortener/internal/http-server/handlers/url/transfer/mocks"
+	mocks2 "url-shortener/internal/http-server/middleware/authenticator/mocks"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestExportHandler(t *testing.T) {
+	const userId = int64(42)
+
+	createdAt := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	urls := []storage.URL{
+		{ID: 1, Alias: "google", URL: "https://google.com", UserID: 7, CreatedAt: createdAt, Clicks: 3},
+	}
+
+	cases := []struct {
+		name        string
+		query       string
+		isAdmin     bool
+		filter      *storage.ExportFilter
+		respCode    int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "CSV",
+			isAdmin:     true,
+			filter:      &storage.ExportFilter{Limit: 500},
+			respCode:    http.StatusOK,
+			contentType: "text/csv",
+			body: "alias,url,user_id,created_at,expires_at,clicks,redirect_type,password_hash,max_clicks,used_clicks,title,preview,domain\n" +
+				"google,https://google.com,7,2024-05-06T07:08:09Z,,3,,,,0,,false,\n",
+		},
+		{
+			name:        "NDJSON of one user",
+			query:       "?format=ndjson&user_id=7",
+			isAdmin:     true,
+			filter:      &storage.ExportFilter{UserID: ptr(int64(7)), Limit: 500},
+			respCode:    http.StatusOK,
+			contentType: "application/x-ndjson",
+			body:        `{"alias":"google","url":"https://google.com","user_id":7,"created_at":"2024-05-06T07:08:09Z","clicks":3}` + "\n",
+		},
+		{
+			name:     "Unknown format",
+			query:    "?format=xml",
+			isAdmin:  true,
+			respCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Not admin",
+			respCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlExporterMock := mocks.NewURLExporter(t)
+			if tc.filter != nil {
+				urlExporterMock.On("ExportURLs", mock.Anything, *tc.filter).
+					Return(urls, nil).
+					Once()
+			}
+
+			isAdminCheckerMock := mocks.NewIsAdminChecker(t)
+			isAdminCheckerMock.On("IsAdmin", mock.Anything, userId).
+				Return(tc.isAdmin, nil).
+				Once()
+
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Get("/admin/urls/export", transfer.NewExport(slogdiscard.NewDiscardLogger(), urlExporterMock, isAdminCheckerMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/admin/urls/export"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+			if tc.respCode != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, tc.contentType, rr.Header().Get("Content-Type"))
+			require.Equal(t, tc.body, rr.Body.String())
+		})
+	}
+}
+
+func TestImportHandler(t *testing.T) {
+	const userId = int64(42)
+
+	cases := []struct {
+		name       string
+		query      string
+		body       string
+		isAdmin    bool
+		onConflict storage.ConflictPolicy
+		// urls expected to be imported, ImportURLs is not called if nil
+		urls      []storage.URL
+		stats     storage.ImportStats
+		mockError error
+		respCode  int
+		respError string
+	}{
+		{
+			name:       "Success",
+			query:      "?on_conflict=skip",
+			body:       "alias,url,user_id\ngoogle,https://google.com,7\nya,https://ya.ru,\n",
+			isAdmin:    true,
+			onConflict: storage.ConflictSkip,
+			urls: []storage.URL{
+				{Alias: "google", URL: "https://google.com", UserID: 7},
+				{Alias: "ya", URL: "https://ya.ru", UserID: userId},
+			},
+			stats:    storage.ImportStats{Created: 1, Skipped: 1},
+			respCode: http.StatusOK,
+		},
+		{
+			name:       "Owner from query",
+			query:      "?format=ndjson&user_id=9",
+			body:       `{"alias": "google", "url": "https://google.com"}`,
+			isAdmin:    true,
+			onConflict: storage.ConflictFail,
+			urls: []storage.URL{
+				{Alias: "google", URL: "https://google.com", UserID: 9},
+			},
+			stats:    storage.ImportStats{Created: 1},
+			respCode: http.StatusOK,
+		},
+		{
+			name:       "Conflict",
+			body:       "alias,url\ngoogle,https://google.com\n",
+			isAdmin:    true,
+			onConflict: storage.ConflictFail,
+			urls: []storage.URL{
+				{Alias: "google", URL: "https://google.com", UserID: userId},
+			},
+			mockError: storage.ErrURLExists,
+			respCode:  http.StatusConflict,
+			respError: "alias already exists",
+		},
+		{
+			name:       "Storage error",
+			body:       "alias,url\ngoogle,https://google.com\n",
+			isAdmin:    true,
+			onConflict: storage.ConflictFail,
+			urls: []storage.URL{
+				{Alias: "google", URL: "https://google.com", UserID: userId},
+			},
+			mockError: errors.New("database is locked"),
+			respCode:  http.StatusInternalServerError,
+			respError: "failed to import urls",
+		},
+		{
+			name:      "Invalid file",
+			body:      "url\nhttps://google.com\n",
+			isAdmin:   true,
+			respCode:  http.StatusBadRequest,
+			respError: "failed to decode file",
+		},
+		{
+			name:      "Reserved alias",
+			body:      "alias,url\nmetrics,https://google.com\n",
+			isAdmin:   true,
+			respCode:  http.StatusBadRequest,
+			respError: "failed to decode file",
+		},
+		{
+			name:      "Domain not allowed",
+			body:      "alias,url,domain\ngoogle,https://google.com,other.example\n",
+			isAdmin:   true,
+			respCode:  http.StatusBadRequest,
+			respError: "failed to decode file",
+		},
+		{
+			name:      "Unknown conflict policy",
+			query:     "?on_conflict=merge",
+			isAdmin:   true,
+			respCode:  http.StatusBadRequest,
+			respError: "on_conflict must be one of skip, overwrite or fail",
+		},
+		{
+			name:      "Not admin",
+			respCode:  http.StatusForbidden,
+			respError: "only admins are allowed to transfer urls",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlImporterMock := mocks.NewURLImporter(t)
+			if tc.urls != nil {
+				urlImporterMock.On("ImportURLs", mock.Anything, mock.MatchedBy(func(urls []storage.URL) bool {
+					return equalIgnoringCreatedAt(tc.urls, urls)
+				}), tc.onConflict).
+					Return(tc.stats, tc.mockError).
+					Once()
+			}
+
+			isAdminCheckerMock := mocks.NewIsAdminChecker(t)
+			isAdminCheckerMock.On("IsAdmin", mock.Anything, userId).
+				Return(tc.isAdmin, nil).
+				Once()
+
+			r := chi.NewRouter()
+			r.Use(mocks2.UserIdAdder(userId))
+			r.Post("/admin/urls/import", transfer.NewImport(slogdiscard.NewDiscardLogger(), urlImporterMock, isAdminCheckerMock, domain.NewAllowlist([]string{"go.example"})))
+
+			req, err := http.NewRequest(http.MethodPost, "/admin/urls/import"+tc.query, strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			var res transfer.ImportResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+			if tc.respError != "" {
+				require.Equal(t, resp.Error(tc.respError), res.Response)
+				return
+			}
+
+			require.Equal(t, resp.StatusOK, res.Status)
+			require.Equal(t, tc.stats.Created, res.Created)
+			require.Equal(t, tc.stats.Updated, res.Updated)
+			require.Equal(t, tc.stats.Skipped, res.Skipped)
+		})
+	}
+}
+
+// equalIgnoringCreatedAt compares urls except creation time set on import.
+func equalIgnoringCreatedAt(expected, actual []storage.URL) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	for i := range expected {
+		u := actual[i]
+		u.CreatedAt = time.Time{}
+		if expected[i] != u {
+			return false
+		}
+	}
+
+	return true
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,75 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// URLUpdater is an autogenerated mock type for the URLUpdater type
+type URLUpdater struct {
+	mock.Mock
+}
+
+// GetURLOwner provides a mock function with given fields: ctx, domain, alias
+func (_m *URLUpdater) GetURLOwner(ctx context.Context, domain string, alias string) (int64, error) {
+	ret := _m.Called(ctx, domain, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetURLOwner")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (int64, error)); ok {
+		return rf(ctx, domain, alias)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int64); ok {
+		r0 = rf(ctx, domain, alias)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, domain, alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateURL provides a mock function with given fields: ctx, domain, alias, upd
+func (_m *URLUpdater) UpdateURL(ctx context.Context, domain string, alias string, upd storage.URLUpdate) error {
+	ret := _m.Called(ctx, domain, alias, upd)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateURL")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, storage.URLUpdate) error); ok {
+		r0 = rf(ctx, domain, alias, upd)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewURLUpdater creates a new instance of URLUpdater. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLUpdater(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLUpdater {
+	mock := &URLUpdater{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,226 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	"url-shortener/internal/lib/access"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+)
+
+// Request contains fields to change. Omitted fields are left unchanged,
+// present ones are validated with the same rules as in save.Request.
+// Null expires_at or empty ttl makes the url never expire.
+type Request struct {
+	URL          *string      `json:"url,omitempty" validate:"omitnil,url"`
+	ExpiresAt    OptionalTime `json:"expires_at"`
+	TTL          *string      `json:"ttl,omitempty"`
+	RedirectType *int         `json:"redirect_type,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+}
+
+// OptionalTime is a time of request that tells omitted value from null.
+type OptionalTime struct {
+	// Set is true if the value is present in request, even if it's null.
+	Set   bool
+	Value *time.Time
+}
+
+func (t *OptionalTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	t.Value = nil
+
+	if string(data) == "null" {
+		return nil
+	}
+
+	return json.Unmarshal(data, &t.Value)
+}
+
+type Response struct {
+	resp.Response
+	Alias string `json:"alias,omitempty"`
+}
+
+// URLUpdater is an interface for changing url by alias.
+// GetURLOwner is used to allow users to update urls they created.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=URLUpdater
+type URLUpdater interface {
+	GetURLOwner(ctx context.Context, domain, alias string) (int64, error)
+	UpdateURL(ctx context.Context, domain, alias string, upd storage.URLUpdate) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=IsAdminChecker
+type IsAdminChecker interface {
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+// New returns handler changing url with given alias. Urls on other domains
+// than the primary one are selected by domain query parameter.
+func New(log *slog.Logger, urlUpdater URLUpdater, isAdminChecker IsAdminChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.update.New"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, span := tracing.Start(r.Context(), op)
+		defer span.End()
+
+		userId, ok := authenticator.UserIdFromContext(r.Context())
+		if !ok {
+			log.Info("failed to get userId from context")
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		var ttl string
+		if req.TTL != nil {
+			ttl = *req.TTL
+		}
+
+		noExpiry := (req.ExpiresAt.Set && req.ExpiresAt.Value == nil) || (req.TTL != nil && ttl == "")
+		if noExpiry && (req.ExpiresAt.Value != nil || ttl != "") {
+			log.Info("invalid expiration", sl.Err(save.ErrExpirationConflict))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(save.ErrExpirationConflict.Error()))
+
+			return
+		}
+
+		expiresAt, err := save.ParseExpiration(req.ExpiresAt.Value, ttl, time.Now())
+		if err != nil {
+			log.Info("invalid expiration", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		upd := storage.URLUpdate{
+			URL:          req.URL,
+			ExpiresAt:    expiresAt,
+			NoExpiry:     noExpiry,
+			RedirectType: req.RedirectType,
+		}
+
+		if upd == (storage.URLUpdate{}) {
+			log.Info("nothing to update")
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("nothing to update"))
+
+			return
+		}
+
+		urlDomain := domain.Normalize(r.URL.Query().Get("domain"))
+
+		err = access.CanManageURL(ctx, urlUpdater, isAdminChecker, urlDomain, alias, userId)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", "alias", alias)
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if errors.Is(err, access.ErrForbidden) {
+			log.Info("user is neither owner nor admin", slog.Int64("user_id", userId))
+
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, resp.Error("you are not allowed to update this url"))
+
+			return
+		}
+		if err != nil {
+			log.Info("failed to check access to url", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		err = urlUpdater.UpdateURL(ctx, urlDomain, alias, upd)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			// deleted between access check and update
+			log.Info("url not found", "alias", alias)
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to update url", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("url updated", "alias", alias)
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Alias:    alias,
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package update_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/handlers/url/update"
+	"url-shortener/internal/http-server/handlers/url/update/mocks"
+	mocks2 "url-shortener/internal/http-server/middleware/authenticator/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	const (
+		userId = int64(1)
+		newURL = "https://example.com/new"
+	)
+
+	redirectType := 301
+
+	cases := []struct {
+		name              string
+		alias             string
+		body              string
+		viaAPIKey         bool
+		shouldGetOwner    bool
+		ownerId           int64
+		getOwnerMockError error
+		shouldCallIsAdmin bool
+		isAdmin           bool
+		shouldUpdate      bool
+		update            *storage.URLUpdate
+		updateMockError   error
+		statusCode        int
+		respError         string
+	}{
+		{
+			name:           "Owner updates url",
+			alias:          "test_alias",
+			body:           `{"url": "` + newURL + `"}`,
+			shouldGetOwner: true,
+			ownerId:        userId,
+			shouldUpdate:   true,
+			statusCode:     http.StatusOK,
+		},
+		{
+			name:           "Admin's api key updates someone else's url",
+			alias:          "test_alias",
+			body:           `{"url": "` + newURL + `"}`,
+			viaAPIKey:      true,
+			shouldGetOwner: true,
+			ownerId:        int64(2),
+			statusCode:     http.StatusForbidden,
+			respError:      "you are not allowed to update this url",
+		},
+		{
+			name:              "Admin updates someone else's url",
+			alias:             "test_alias",
+			body:              `{"url": "` + newURL + `"}`,
+			shouldGetOwner:    true,
+			ownerId:           int64(2),
+			shouldCallIsAdmin: true,
+			isAdmin:           true,
+			shouldUpdate:      true,
+			statusCode:        http.StatusOK,
+		},
+		{
+			name:              "User is neither owner nor admin",
+			alias:             "test_alias",
+			body:              `{"url": "` + newURL + `"}`,
+			shouldGetOwner:    true,
+			ownerId:           int64(2),
+			shouldCallIsAdmin: true,
+			statusCode:        http.StatusForbidden,
+			respError:         "you are not allowed to update this url",
+		},
+		{
+			name:              "URL not found",
+			alias:             "test_alias",
+			body:              `{"url": "` + newURL + `"}`,
+			shouldGetOwner:    true,
+			getOwnerMockError: storage.ErrURLNotFound,
+			statusCode:        http.StatusNotFound,
+			respError:         "not found",
+		},
+		{
+			name:           "Redirect type",
+			alias:          "test_alias",
+			body:           `{"redirect_type": 301}`,
+			shouldGetOwner: true,
+			ownerId:        userId,
+			shouldUpdate:   true,
+			update:         &storage.URLUpdate{RedirectType: &redirectType},
+			statusCode:     http.StatusOK,
+		},
+		{
+			name:       "Invalid redirect type",
+			alias:      "test_alias",
+			body:       `{"redirect_type": 303}`,
+			statusCode: http.StatusBadRequest,
+			respError:  "field RedirectType must be one of 301 302 307 308",
+		},
+		{
+			name:           "Null expires_at clears expiry",
+			alias:          "test_alias",
+			body:           `{"expires_at": null}`,
+			shouldGetOwner: true,
+			ownerId:        userId,
+			shouldUpdate:   true,
+			update:         &storage.URLUpdate{NoExpiry: true},
+			statusCode:     http.StatusOK,
+		},
+		{
+			name:           "Empty ttl clears expiry",
+			alias:          "test_alias",
+			body:           `{"ttl": ""}`,
+			shouldGetOwner: true,
+			ownerId:        userId,
+			shouldUpdate:   true,
+			update:         &storage.URLUpdate{NoExpiry: true},
+			statusCode:     http.StatusOK,
+		},
+		{
+			name:       "Clearing and setting expiry",
+			alias:      "test_alias",
+			body:       `{"expires_at": null, "ttl": "1h"}`,
+			statusCode: http.StatusBadRequest,
+			respError:  save.ErrExpirationConflict.Error(),
+		},
+		{
+			name:       "Invalid URL",
+			alias:      "test_alias",
+			body:       `{"url": "some invalid URL"}`,
+			statusCode: http.StatusBadRequest,
+			respError:  "field URL is not a valid URL",
+		},
+		{
+			name:       "Nothing to update",
+			alias:      "test_alias",
+			body:       `{}`,
+			statusCode: http.StatusBadRequest,
+			respError:  "nothing to update",
+		},
+		{
+			name:       "Malformed body",
+			alias:      "test_alias",
+			body:       "malformed body",
+			statusCode: http.StatusBadRequest,
+			respError:  "failed to decode request",
+		},
+		{
+			name:            "UpdateURL Error",
+			alias:           "test_alias",
+			body:            `{"url": "` + newURL + `"}`,
+			shouldGetOwner:  true,
+			ownerId:         userId,
+			shouldUpdate:    true,
+			updateMockError: errors.New("unexpected error"),
+			statusCode:      http.StatusInternalServerError,
+			respError:       "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			isAdminCheckerMock := mocks.NewIsAdminChecker(t)
+			if tc.shouldCallIsAdmin {
+				isAdminCheckerMock.On("IsAdmin", mock.Anything, userId).
+					Return(tc.isAdmin, nil).
+					Once()
+			}
+
+			urlUpdaterMock := mocks.NewURLUpdater(t)
+			if tc.shouldGetOwner {
+				urlUpdaterMock.On("GetURLOwner", mock.Anything, "", tc.alias).
+					Return(tc.ownerId, tc.getOwnerMockError).
+					Once()
+			}
+			if tc.shouldUpdate {
+				u := newURL
+				upd := storage.URLUpdate{URL: &u}
+				if tc.update != nil {
+					upd = *tc.update
+				}
+				urlUpdaterMock.On("UpdateURL", mock.Anything, "", tc.alias, upd).
+					Return(tc.updateMockError).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			if tc.viaAPIKey {
+				r.Use(mocks2.APIKeyAdder(userId, "urls:write"))
+			} else {
+				r.Use(mocks2.UserIdAdder(userId))
+			}
+			r.Patch("/{alias}", update.New(slogdiscard.NewDiscardLogger(), urlUpdaterMock, isAdminCheckerMock))
+
+			req, err := http.NewRequest(http.MethodPatch, "/"+tc.alias, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.statusCode, rr.Code)
+
+			var resp update.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strings"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+)
+
+// APIKeyHeader carries api key, it may also be sent as bearer token.
+const APIKeyHeader = "X-API-Key"
+
+var (
+	ScopesCtxKey = &contextKey{"Scopes"}
+)
+
+// APIKeyGetter is an interface for getting api key by its hash.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50.0 --name=APIKeyGetter
+type APIKeyGetter interface {
+	GetAPIKey(ctx context.Context, hash string) (storage.APIKey, error)
+}
+
+// APIKey authenticates requests carrying api key. It sets UserIdCtxKey
+// like Authenticator does, so Authenticator lets them through, and
+// ScopesCtxKey checked by RequireScope. Requests without key are passed
+// on untouched.
+func APIKey(log *slog.Logger, keyGetter APIKeyGetter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			key := keyFromRequest(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			k, err := keyGetter.GetAPIKey(r.Context(), apikey.Hash(key))
+			if errors.Is(err, storage.ErrAPIKeyNotFound) {
+				log.Info("unknown api key")
+				responseUnauthorized(w, r)
+				return
+			}
+			if err != nil {
+				log.Error("failed to get api key", sl.Err(err))
+
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserIdCtxKey, k.UserID)
+			ctx = context.WithValue(ctx, ScopesCtxKey, k.Scopes)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// RequireScope rejects requests authenticated by api key without scope.
+// Requests authenticated by user token are allowed everything.
+func RequireScope(log *slog.Logger, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := ScopesFromContext(r.Context())
+			if ok && !apikey.HasScope(scopes, scope) {
+				log.Info("api key has no scope", slog.String("scope", scope))
+
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, resp.Error("api key has no "+scope+" scope"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// DenyAPIKey rejects requests authenticated by api key, e.g. ones
+// managing the keys.
+func DenyAPIKey(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := ScopesFromContext(r.Context()); ok {
+				log.Info("api key is not allowed")
+
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, resp.Error("api keys are not allowed here"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// ScopesFromContext returns scopes of api key stored by APIKey. It returns
+// false for requests authenticated by user token.
+func ScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(ScopesCtxKey).([]string)
+
+	return scopes, ok
+}
+
+// keyFromRequest returns api key of r, or empty string if it has none.
+// Bearer tokens that are not keys are left to Authenticator.
+func keyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && apikey.IsKey(token) {
+		return token
+	}
+
+	return ""
+}
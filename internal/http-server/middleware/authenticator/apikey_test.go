@@ -0,0 +1,152 @@
+package authenticator_test
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	"url-shortener/internal/http-server/middleware/authenticator/mocks"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestAPIKey(t *testing.T) {
+	ja := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, err := ja.Encode(map[string]interface{}{"uid": 42})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		header   string
+		value    string
+		respCode int
+		userId   int64
+	}{
+		{
+			name:     "Key in header",
+			method:   http.MethodGet,
+			path:     "/url",
+			header:   authenticator.APIKeyHeader,
+			value:    "us_reader",
+			respCode: http.StatusOK,
+			userId:   7,
+		},
+		{
+			name:     "Key as bearer token",
+			method:   http.MethodGet,
+			path:     "/url",
+			header:   "Authorization",
+			value:    "Bearer us_reader",
+			respCode: http.StatusOK,
+			userId:   7,
+		},
+		{
+			name:     "User token",
+			method:   http.MethodPost,
+			path:     "/url",
+			header:   "Authorization",
+			value:    "Bearer " + token,
+			respCode: http.StatusOK,
+			userId:   42,
+		},
+		{
+			name:     "Key without scope",
+			method:   http.MethodPost,
+			path:     "/url",
+			header:   authenticator.APIKeyHeader,
+			value:    "us_reader",
+			respCode: http.StatusForbidden,
+		},
+		{
+			name:     "Key on route denying keys",
+			method:   http.MethodGet,
+			path:     "/apikeys",
+			header:   authenticator.APIKeyHeader,
+			value:    "us_reader",
+			respCode: http.StatusForbidden,
+		},
+		{
+			name:     "User token on route denying keys",
+			method:   http.MethodGet,
+			path:     "/apikeys",
+			header:   "Authorization",
+			value:    "Bearer " + token,
+			respCode: http.StatusOK,
+			userId:   42,
+		},
+		{
+			name:     "Revoked key",
+			method:   http.MethodGet,
+			path:     "/url",
+			header:   authenticator.APIKeyHeader,
+			value:    "us_revoked",
+			respCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Storage failure",
+			method:   http.MethodGet,
+			path:     "/url",
+			header:   authenticator.APIKeyHeader,
+			value:    "us_broken",
+			respCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "No credentials",
+			method:   http.MethodGet,
+			path:     "/url",
+			respCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyGetterMock := mocks.NewAPIKeyGetter(t)
+			keyGetterMock.On("GetAPIKey", mock.Anything, apikey.Hash("us_reader")).
+				Return(storage.APIKey{UserID: 7, Scopes: []string{apikey.ScopeURLsRead}}, nil).Maybe()
+			keyGetterMock.On("GetAPIKey", mock.Anything, apikey.Hash("us_revoked")).
+				Return(storage.APIKey{}, storage.ErrAPIKeyNotFound).Maybe()
+			keyGetterMock.On("GetAPIKey", mock.Anything, apikey.Hash("us_broken")).
+				Return(storage.APIKey{}, errors.New("unexpected error")).Maybe()
+
+			log := slogdiscard.NewDiscardLogger()
+			userIdWriter := func(w http.ResponseWriter, r *http.Request) {
+				userId, _ := authenticator.UserIdFromContext(r.Context())
+				_, _ = w.Write([]byte(strconv.FormatInt(userId, 10)))
+			}
+
+			r := chi.NewRouter()
+			r.Use(jwtauth.Verifier(ja))
+			r.Use(authenticator.APIKey(log, keyGetterMock))
+			r.Use(authenticator.Authenticator(log, ja))
+			r.With(authenticator.RequireScope(log, apikey.ScopeURLsRead)).Get("/url", userIdWriter)
+			r.With(authenticator.RequireScope(log, apikey.ScopeURLsWrite)).Post("/url", userIdWriter)
+			r.With(authenticator.DenyAPIKey(log)).Get("/apikeys", userIdWriter)
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+			if tc.respCode == http.StatusOK {
+				require.Equal(t, strconv.FormatInt(tc.userId, 10), rr.Body.String())
+			}
+		})
+	}
+}
@@ -26,9 +26,16 @@ var (
 	UserIdCtxKey = &contextKey{"UserId"}
 )
 
+// Authenticator authenticates requests by user token verified by
+// jwtauth.Verifier. Requests already authenticated by APIKey are passed on.
 func Authenticator(log *slog.Logger, ja *jwtauth.JWTAuth) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		hfn := func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := UserIdFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			token, claims, err := jwtauth.FromContext(r.Context())
 
 			if err != nil {
@@ -67,6 +74,13 @@ func Authenticator(log *slog.Logger, ja *jwtauth.JWTAuth) func(http.Handler) htt
 	}
 }
 
+// UserIdFromContext returns id of the authenticated user stored by Authenticator.
+func UserIdFromContext(ctx context.Context) (int64, bool) {
+	userId, ok := ctx.Value(UserIdCtxKey).(int64)
+
+	return userId, ok
+}
+
 func responseUnauthorized(w http.ResponseWriter, r *http.Request) {
 	render.Status(r, http.StatusUnauthorized)
 	render.JSON(w, r, resp.Response{
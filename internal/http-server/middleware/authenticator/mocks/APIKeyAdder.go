@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+	"net/http"
+	"url-shortener/internal/http-server/middleware/authenticator"
+)
+
+// APIKeyAdder authenticates requests like authenticator.APIKey does
+// with a key of user with given scopes.
+func APIKeyAdder(userId int64, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), authenticator.UserIdCtxKey, userId)
+			ctx = context.WithValue(ctx, authenticator.ScopesCtxKey, append([]string{}, scopes...))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
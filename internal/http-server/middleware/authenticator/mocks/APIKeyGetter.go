@@ -0,0 +1,57 @@
+// Code generated by mockery v2.50.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// APIKeyGetter is an autogenerated mock type for the APIKeyGetter type
+type APIKeyGetter struct {
+	mock.Mock
+}
+
+// GetAPIKey provides a mock function with given fields: ctx, hash
+func (_m *APIKeyGetter) GetAPIKey(ctx context.Context, hash string) (storage.APIKey, error) {
+	ret := _m.Called(ctx, hash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAPIKey")
+	}
+
+	var r0 storage.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (storage.APIKey, error)); ok {
+		return rf(ctx, hash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) storage.APIKey); ok {
+		r0 = rf(ctx, hash)
+	} else {
+		r0 = ret.Get(0).(storage.APIKey)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewAPIKeyGetter creates a new instance of APIKeyGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAPIKeyGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *APIKeyGetter {
+	mock := &APIKeyGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
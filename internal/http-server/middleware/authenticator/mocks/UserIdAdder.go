@@ -15,7 +15,6 @@ func UserIdAdder(userId int64) func(http.Handler) http.Handler {
 				userId,
 			)
 			next.ServeHTTP(w, r.WithContext(ctx))
-			next.ServeHTTP(w, r)
 		}
 		return http.HandlerFunc(hfn)
 	}
@@ -7,7 +7,14 @@ import (
 	"time"
 )
 
-func New(log *slog.Logger) func(next http.Handler) http.Handler {
+// New returns middleware logging completed requests.
+// Requests to skipPaths, e.g. health probes, are not logged.
+func New(log *slog.Logger, skipPaths ...string) func(next http.Handler) http.Handler {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
 	return func(next http.Handler) http.Handler {
 		log = log.With(
 			slog.String("component", "middleware/logger"),
@@ -16,6 +23,12 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 		log.Info("logger middleware enabled")
 
 		fn := func(w http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
 			entry := log.With(
 				slog.String("method", r.Method),
 				slog.String("path", r.URL.Path),
@@ -0,0 +1,46 @@
+package mwMetrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/lib/metrics"
+)
+
+// unmatchedRoute labels requests no route matched, so
+// scanning for random paths doesn't blow up cardinality.
+const unmatchedRoute = "unmatched"
+
+// New returns middleware counting requests and observing their duration
+// by route pattern and status.
+func New() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			t1 := time.Now()
+			defer func() {
+				// pattern is known only after routing
+				route := unmatchedRoute
+				if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+					route = rctx.RoutePattern()
+				}
+
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				labels := []string{r.Method, route, strconv.Itoa(status)}
+				metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+				metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(t1).Seconds())
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,161 @@
+package mwRateLimit
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"golang.org/x/time/rate"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+)
+
+// sweepInterval is how often buckets of idle clients are dropped.
+const sweepInterval = time.Minute
+
+// Limiter is a set of token buckets, one per client key. Every bucket
+// holds up to burst tokens and is refilled with requests tokens per period.
+type Limiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+// NewLimiter returns limiter allowing requests per period with bursts
+// of up to burst requests. Burst less than 1 is treated as 1.
+func NewLimiter(requests int, period time.Duration, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		limit:     rate.Limit(float64(requests) / period.Seconds()),
+		burst:     burst,
+		buckets:   make(map[string]*rate.Limiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key. If the bucket is empty it
+// returns false and time after which the request would be allowed.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = rate.NewLimiter(l.limit, l.burst)
+		l.buckets[key] = bucket
+	}
+
+	reservation := bucket.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// sweep drops full buckets, they are no different from new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+// KeyFunc returns key of the client that made the request.
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys requests by client IP.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// ByIPAndURL keys requests by client IP and the url they are made to, so
+// a client is limited separately for every url. The url is resolved like
+// redirects do: by domain of Host header and alias without the "+" suffix
+// of preview pages.
+func ByIPAndURL(domains domain.Allowlist) KeyFunc {
+	return func(r *http.Request) string {
+		alias := strings.TrimSuffix(chi.URLParam(r, "alias"), "+")
+
+		return ByIP(r) + " url:" + domains.FromHost(r.Host) + "/" + alias
+	}
+}
+
+// ByUserID keys requests by id of the authenticated user,
+// it falls back to client IP for anonymous requests.
+func ByUserID(r *http.Request) string {
+	userId, ok := authenticator.UserIdFromContext(r.Context())
+	if !ok {
+		return ByIP(r)
+	}
+
+	return "user:" + strconv.FormatInt(userId, 10)
+}
+
+// New returns middleware rejecting requests of clients that exhausted their
+// bucket in limiter with 429 Too Many Requests. Nil limiter disables limiting.
+func New(log *slog.Logger, limiter *Limiter, key KeyFunc) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+
+		log := log.With(
+			slog.String("component", "middleware/ratelimit"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			clientKey := key(r)
+
+			ok, retryAfter := limiter.Allow(clientKey)
+			if !ok {
+				log.Info("rate limit exceeded",
+					slog.String("client", clientKey),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+				render.Status(r, http.StatusTooManyRequests)
+				render.JSON(w, r, resp.Error("too many requests"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,144 @@
+package mwRateLimit_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/middleware/authenticator"
+	mwRateLimit "url-shortener/internal/http-server/middleware/ratelimit"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+func TestRateLimit(t *testing.T) {
+	type request struct {
+		remoteAddr string
+		userId     int64
+		// path defaults to /url
+		path     string
+		host     string
+		respCode int
+	}
+
+	cases := []struct {
+		name     string
+		key      mwRateLimit.KeyFunc
+		requests []request
+	}{
+		{
+			name: "Burst exhausted by IP",
+			key:  mwRateLimit.ByIP,
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1001", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1002", respCode: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "Separate buckets by IP",
+			key:  mwRateLimit.ByIP,
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.2:1000", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", respCode: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "Same user from different IPs",
+			key:  mwRateLimit.ByUserID,
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", userId: 1, respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.2:1000", userId: 1, respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.3:1000", userId: 1, respCode: http.StatusTooManyRequests},
+				{remoteAddr: "10.0.0.3:1000", userId: 2, respCode: http.StatusOK},
+			},
+		},
+		{
+			name: "Separate buckets by IP and url",
+			key:  mwRateLimit.ByIPAndURL(domain.NewAllowlist([]string{"go.example"})),
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", path: "/first", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", path: "/first", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", path: "/second", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.2:1000", path: "/first", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", path: "/first", host: "go.example", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", path: "/first", respCode: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "Preview suffix and host case share url bucket",
+			key:  mwRateLimit.ByIPAndURL(domain.NewAllowlist([]string{"go.example"})),
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", path: "/first", host: "go.example", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", path: "/first+", host: "GO.example:8080", respCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", path: "/first+", host: "go.example", respCode: http.StatusTooManyRequests},
+				{remoteAddr: "10.0.0.1:1000", path: "/first", host: "unknown.example", respCode: http.StatusOK},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			limiter := mwRateLimit.NewLimiter(1, time.Minute, 2)
+			handler := chi.NewRouter()
+			handler.With(mwRateLimit.New(slogdiscard.NewDiscardLogger(), limiter, tc.key)).
+				Post("/{alias}", func(w http.ResponseWriter, r *http.Request) {})
+
+			for _, rq := range tc.requests {
+				path := rq.path
+				if path == "" {
+					path = "/url"
+				}
+
+				req := httptest.NewRequest(http.MethodPost, path, nil)
+				req.RemoteAddr = rq.remoteAddr
+				if rq.host != "" {
+					req.Host = rq.host
+				}
+				if rq.userId != 0 {
+					req = req.WithContext(context.WithValue(req.Context(), authenticator.UserIdCtxKey, rq.userId))
+				}
+
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				require.Equal(t, rq.respCode, rr.Code)
+
+				if rq.respCode != http.StatusTooManyRequests {
+					continue
+				}
+
+				// one token per minute, the bucket was just emptied
+				require.Equal(t, "60", rr.Header().Get("Retry-After"))
+
+				var res resp.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+				require.Equal(t, resp.Error("too many requests"), res)
+			}
+		})
+	}
+}
+
+func TestRateLimit_Disabled(t *testing.T) {
+	handler := mwRateLimit.New(slogdiscard.NewDiscardLogger(), nil, mwRateLimit.ByIP)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	for i := 0; i < 100; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/alias", nil))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+}
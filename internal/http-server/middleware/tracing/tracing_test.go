@@ -0,0 +1,99 @@
+package mwTracing_test
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url-shortener/internal/http-server/handlers/redirect"
+	"url-shortener/internal/http-server/handlers/redirect/mocks"
+	mwTracing "url-shortener/internal/http-server/middleware/tracing"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/instrumented"
+	"url-shortener/internal/storage/memory"
+)
+
+const (
+	traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID = "00f067aa0ba902b7"
+)
+
+func TestTracing(t *testing.T) {
+	cases := []struct {
+		name        string
+		traceparent string
+	}{
+		{
+			name: "New trace",
+		},
+		{
+			name:        "Continued trace",
+			traceparent: "00-" + traceID + "-" + parentID + "-01",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := tracing.Setup(exporter, 1)
+			t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+			urlStorage := memory.New()
+			_, err := urlStorage.SaveURL(context.Background(), "https://google.com", "test_alias", 1, storage.URLOptions{})
+			require.NoError(t, err)
+
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			clickRecorderMock.On("Record", "", "test_alias", mock.Anything, mock.Anything, mock.Anything).Once()
+
+			r := chi.NewRouter()
+			r.Use(mwTracing.New())
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), instrumented.New(urlStorage), instrumented.New(urlStorage), clickRecorderMock, redirect.Options{
+				DefaultType: http.StatusFound,
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/test_alias", nil)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusFound, rr.Code)
+			require.NoError(t, tp.ForceFlush(context.Background()))
+
+			spans := exporter.GetSpans().Snapshots()
+			require.Len(t, spans, 3)
+
+			byName := make(map[string]int, len(spans))
+			for i, span := range spans {
+				byName[span.Name()] = i
+			}
+			require.Contains(t, byName, "GET /{alias}")
+			require.Contains(t, byName, "handlers.url.redirect.New")
+			require.Contains(t, byName, "storage.get_url")
+
+			server := spans[byName["GET /{alias}"]]
+			handler := spans[byName["handlers.url.redirect.New"]]
+			query := spans[byName["storage.get_url"]]
+
+			require.Equal(t, server.SpanContext().SpanID(), handler.Parent().SpanID())
+			require.Equal(t, handler.SpanContext().SpanID(), query.Parent().SpanID())
+			require.Equal(t, server.SpanContext().TraceID(), query.SpanContext().TraceID())
+
+			if tc.traceparent != "" {
+				require.Equal(t, traceID, server.SpanContext().TraceID().String())
+				require.Equal(t, parentID, server.Parent().SpanID().String())
+				require.True(t, server.Parent().IsRemote())
+			} else {
+				require.False(t, server.Parent().IsValid())
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package janitor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+	"url-shortener/internal/lib/logger/sl"
+)
+
+type ExpiredURLsDeleter interface {
+	DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Janitor periodically purges expired and used up urls from storage. They
+// are kept for retention to answer 410 Gone instead of 404 Not Found.
+type Janitor struct {
+	log       *slog.Logger
+	deleter   ExpiredURLsDeleter
+	interval  time.Duration
+	retention time.Duration
+
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// New returns janitor purging urls every interval. Interval that is not
+// positive disables purging.
+func New(log *slog.Logger, deleter ExpiredURLsDeleter, interval, retention time.Duration) *Janitor {
+	return &Janitor{
+		log:       log.With(slog.String("component", "janitor")),
+		deleter:   deleter,
+		interval:  interval,
+		retention: retention,
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs purging in background. It must be called once.
+func (j *Janitor) Start() {
+	if j.interval <= 0 {
+		j.log.Info("purging of expired urls is disabled")
+		close(j.done)
+
+		return
+	}
+
+	go j.run()
+}
+
+// Stop waits for the current purge to finish and stops the janitor.
+func (j *Janitor) Stop(ctx context.Context) error {
+	j.once.Do(func() { close(j.quit) })
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Purge deletes urls that expired or ran out of clicks longer than
+// retention ago.
+func (j *Janitor) Purge() {
+	deleted, err := j.deleter.DeleteExpiredURLs(context.Background(), time.Now().Add(-j.retention))
+	if err != nil {
+		j.log.Error("failed to delete expired urls", sl.Err(err))
+
+		return
+	}
+
+	if deleted > 0 {
+		j.log.Info("expired urls deleted", slog.Int64("count", deleted))
+	}
+}
+
+func (j *Janitor) run() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.Purge()
+		case <-j.quit:
+			return
+		}
+	}
+}
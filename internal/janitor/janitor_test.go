@@ -0,0 +1,86 @@
+package janitor_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/janitor"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+type expiredURLsDeleterStub struct {
+	mu      sync.Mutex
+	befores []time.Time
+	err     error
+}
+
+func (s *expiredURLsDeleterStub) DeleteExpiredURLs(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.befores = append(s.befores, before)
+
+	return 1, s.err
+}
+
+func (s *expiredURLsDeleterStub) calls() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]time.Time(nil), s.befores...)
+}
+
+func TestJanitor_PurgesOnTicks(t *testing.T) {
+	deleter := &expiredURLsDeleterStub{}
+
+	j := janitor.New(slogdiscard.NewDiscardLogger(), deleter, 10*time.Millisecond, time.Hour)
+	started := time.Now()
+	j.Start()
+
+	require.Eventually(t, func() bool { return len(deleter.calls()) >= 2 }, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, j.Stop(ctx))
+
+	// urls expired within retention are kept
+	before := deleter.calls()[0]
+	require.WithinDuration(t, started.Add(-time.Hour), before, time.Second)
+
+	// no purges after stop, stopping twice is fine
+	n := len(deleter.calls())
+	time.Sleep(30 * time.Millisecond)
+	require.Len(t, deleter.calls(), n)
+	require.NoError(t, j.Stop(ctx))
+}
+
+func TestJanitor_KeepsRunningOnError(t *testing.T) {
+	deleter := &expiredURLsDeleterStub{err: errors.New("unexpected error")}
+
+	j := janitor.New(slogdiscard.NewDiscardLogger(), deleter, 10*time.Millisecond, 0)
+	j.Start()
+
+	require.Eventually(t, func() bool { return len(deleter.calls()) >= 2 }, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, j.Stop(ctx))
+}
+
+func TestJanitor_Disabled(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		deleter := &expiredURLsDeleterStub{}
+
+		j := janitor.New(slogdiscard.NewDiscardLogger(), deleter, interval, 0)
+		j.Start()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		require.NoError(t, j.Stop(ctx))
+		cancel()
+
+		require.Empty(t, deleter.calls())
+	}
+}
@@ -0,0 +1,61 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"url-shortener/internal/http-server/middleware/authenticator"
+)
+
+var (
+	ErrForbidden = errors.New("forbidden")
+)
+
+type URLOwnerGetter interface {
+	GetURLOwner(ctx context.Context, domain, alias string) (int64, error)
+}
+
+type IsAdminChecker interface {
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+// CanManageURL checks that user is allowed to change url with given alias
+// on domain.
+// Owners can manage their own urls, admins can manage any url unless
+// the request is authenticated by api key.
+// It returns storage.ErrURLNotFound if there is no such url and
+// ErrForbidden if user is neither owner nor admin.
+func CanManageURL(
+	ctx context.Context,
+	ownerGetter URLOwnerGetter,
+	isAdminChecker IsAdminChecker,
+	domain, alias string,
+	userID int64,
+) error {
+	const op = "access.CanManageURL"
+
+	ownerID, err := ownerGetter.GetURLOwner(ctx, domain, alias)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ownerID == userID {
+		return nil
+	}
+
+	// api keys of admins act only on urls of their owner
+	if _, ok := authenticator.ScopesFromContext(ctx); ok {
+		return ErrForbidden
+	}
+
+	isAdmin, err := isAdminChecker.IsAdmin(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !isAdmin {
+		return ErrForbidden
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+// Package alias contains strategies of alias generation for urls saved
+// without an alias and validation of aliases given by users.
+package alias
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+const (
+	StrategyRandom     = "random"
+	StrategySequential = "sequential"
+	StrategyHashids    = "hashids"
+	StrategyWords      = "words"
+)
+
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+var (
+	ErrInvalid  = errors.New("field alias must contain only letters, digits, - and _")
+	ErrReserved = errors.New("alias is reserved")
+)
+
+// reserved are first path segments of routes other than redirects.
+var reserved = map[string]bool{
+	"url":      true,
+	"admin":    true,
+	"healthz":  true,
+	"readyz":   true,
+	"metrics":  true,
+	"apikeys":  true,
+	"login":    true,
+	"register": true,
+}
+
+// Validate checks that alias given by user is made of letters, digits, '-'
+// and '_', so it can't end with '+' of preview pages, and doesn't shadow
+// other routes.
+func Validate(a string) error {
+	for _, c := range a {
+		isValid := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+		if !isValid {
+			return ErrInvalid
+		}
+	}
+
+	if reserved[strings.ToLower(a)] {
+		return ErrReserved
+	}
+
+	return nil
+}
+
+// Generator generates aliases of given length. Strategies that
+// can't produce exact length treat it as a hint.
+type Generator interface {
+	Generate(ctx context.Context, length int) (string, error)
+}
+
+// GeneratorFunc adapts ordinary function to Generator.
+type GeneratorFunc func(ctx context.Context, length int) (string, error)
+
+func (f GeneratorFunc) Generate(ctx context.Context, length int) (string, error) {
+	return f(ctx, length)
+}
+
+// Sequence returns unique increasing ids.
+type Sequence interface {
+	NextAliasID(ctx context.Context) (int64, error)
+}
+
+// NewStrategies returns generators of all strategies by their names.
+// ID-based strategies take ids from seq, salt is used by hashids.
+func NewStrategies(seq Sequence, salt string) map[string]Generator {
+	return map[string]Generator{
+		StrategyRandom:     NewRandom(),
+		StrategySequential: NewSequential(seq),
+		StrategyHashids:    NewHashids(seq, salt),
+		StrategyWords:      NewWords(),
+	}
+}
+
+// encode returns n written with digits from alphabet,
+// left padded with zero digit up to length.
+func encode(n uint64, alphabet string, length int) string {
+	base := uint64(len(alphabet))
+
+	var digits []byte
+	for n > 0 {
+		digits = append(digits, alphabet[n%base])
+		n /= base
+	}
+
+	var sb strings.Builder
+	for i := len(digits); i < length; i++ {
+		sb.WriteByte(alphabet[0])
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		sb.WriteByte(digits[i])
+	}
+
+	return sb.String()
+}
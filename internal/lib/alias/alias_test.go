@@ -0,0 +1,158 @@
+package alias_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"url-shortener/internal/lib/alias"
+)
+
+// counter is a sequence starting from 1.
+type counter struct {
+	last int64
+	err  error
+}
+
+func (c *counter) NextAliasID(context.Context) (int64, error) {
+	c.last++
+
+	return c.last, c.err
+}
+
+var base62Re = regexp.MustCompile(`^[0-9a-zA-Z]+$`)
+
+func TestRandom(t *testing.T) {
+	gen := alias.NewRandom()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		a, err := gen.Generate(context.Background(), 8)
+		require.NoError(t, err)
+		require.Len(t, a, 8)
+		require.Regexp(t, base62Re, a)
+		require.False(t, seen[a])
+
+		seen[a] = true
+	}
+}
+
+func TestSequential(t *testing.T) {
+	seq := &counter{}
+	gen := alias.NewSequential(seq)
+
+	cases := []struct {
+		id     int64
+		length int
+		alias  string
+	}{
+		{id: 1, length: 6, alias: "000001"},
+		{id: 61, length: 6, alias: "00000Z"},
+		{id: 62, length: 6, alias: "000010"},
+		{id: 63, length: 1, alias: "11"},
+	}
+
+	for _, tc := range cases {
+		seq.last = tc.id - 1
+
+		a, err := gen.Generate(context.Background(), tc.length)
+		require.NoError(t, err)
+		require.Equal(t, tc.alias, a)
+	}
+
+	seq.err = errors.New("unexpected error")
+	_, err := gen.Generate(context.Background(), 6)
+	require.Error(t, err)
+}
+
+func TestHashids(t *testing.T) {
+	gen := alias.NewHashids(&counter{}, "salt")
+
+	seen := make(map[string]bool)
+	var prev string
+	for i := 0; i < 5000; i++ {
+		a, err := gen.Generate(context.Background(), 6)
+		require.NoError(t, err)
+		require.Len(t, a, 6)
+		require.Regexp(t, base62Re, a)
+		require.False(t, seen[a], "duplicate alias %s", a)
+
+		// neighbouring ids don't share a prefix
+		if prev != "" {
+			require.NotEqual(t, prev[:5], a[:5])
+		}
+
+		seen[a] = true
+		prev = a
+	}
+
+	// ids outgrowing length make aliases longer
+	big := alias.NewHashids(&counter{last: 62 * 62 * 62}, "salt")
+	a, err := big.Generate(context.Background(), 3)
+	require.NoError(t, err)
+	require.Len(t, a, 5)
+
+	// salt changes aliases
+	a1, err := alias.NewHashids(&counter{}, "salt").Generate(context.Background(), 6)
+	require.NoError(t, err)
+	a2, err := alias.NewHashids(&counter{}, "pepper").Generate(context.Background(), 6)
+	require.NoError(t, err)
+	require.NotEqual(t, a1, a2)
+}
+
+func TestWords(t *testing.T) {
+	gen := alias.NewWords()
+
+	cases := []struct {
+		length int
+		re     string
+	}{
+		{length: 0, re: `^[a-z]+-[a-z]+-\d$`},
+		{length: 6, re: `^[a-z]+-[a-z]+-\d{1,2}$`},
+		{length: 8, re: `^[a-z]+-[a-z]+-\d{1,4}$`},
+	}
+
+	for _, tc := range cases {
+		a, err := gen.Generate(context.Background(), tc.length)
+		require.NoError(t, err)
+		require.Regexp(t, tc.re, a)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		alias string
+		err   error
+	}{
+		{alias: "brave-otter_42"},
+		{alias: "urls"},
+		{alias: "google+", err: alias.ErrInvalid},
+		{alias: "a/b", err: alias.ErrInvalid},
+		{alias: "привет", err: alias.ErrInvalid},
+		{alias: "url", err: alias.ErrReserved},
+		{alias: "Metrics", err: alias.ErrReserved},
+	}
+
+	for _, tc := range cases {
+		require.ErrorIs(t, alias.Validate(tc.alias), tc.err, tc.alias)
+	}
+}
+
+func TestNewStrategies(t *testing.T) {
+	strategies := alias.NewStrategies(&counter{}, "salt")
+
+	for _, name := range []string{
+		alias.StrategyRandom,
+		alias.StrategySequential,
+		alias.StrategyHashids,
+		alias.StrategyWords,
+	} {
+		gen, ok := strategies[name]
+		require.True(t, ok, name)
+
+		a, err := gen.Generate(context.Background(), 6)
+		require.NoError(t, err)
+		require.NotEmpty(t, a)
+	}
+}
@@ -0,0 +1,62 @@
+package alias
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hashids generates obfuscated ids from a sequence the way Hashids does.
+// The first char of an alias is a "lottery" char picked by id, the rest is
+// the id encoded with alphabet shuffled by salt and the lottery char, so
+// neighbouring ids look unrelated. Aliases are still unique per salt.
+type Hashids struct {
+	seq      Sequence
+	salt     string
+	alphabet string
+}
+
+func NewHashids(seq Sequence, salt string) *Hashids {
+	return &Hashids{
+		seq:      seq,
+		salt:     salt,
+		alphabet: shuffle(base62Alphabet, salt),
+	}
+}
+
+func (h *Hashids) Generate(ctx context.Context, length int) (string, error) {
+	const op = "alias.Hashids.Generate"
+
+	id, err := h.seq.NextAliasID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return h.encode(uint64(id), length), nil
+}
+
+func (h *Hashids) encode(id uint64, length int) string {
+	lottery := h.alphabet[id%uint64(len(h.alphabet))]
+	alphabet := shuffle(h.alphabet, string(lottery)+h.salt)
+
+	return string(lottery) + encode(id, alphabet, length-1)
+}
+
+// shuffle is the consistent shuffle of Hashids: the same
+// alphabet and salt always give the same permutation.
+func shuffle(alphabet string, salt string) string {
+	if salt == "" {
+		return alphabet
+	}
+
+	res := []byte(alphabet)
+	for i, v, p := len(res)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		c := int(salt[v])
+		p += c
+		j := (c + v + p) % i
+		res[i], res[j] = res[j], res[i]
+		v++
+	}
+
+	return string(res)
+}
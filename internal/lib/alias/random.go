@@ -0,0 +1,49 @@
+package alias
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// Random generates crypto-secure random base62 aliases.
+type Random struct {
+	source io.Reader
+}
+
+func NewRandom() *Random {
+	return &Random{source: rand.Reader}
+}
+
+// NewRandomFrom returns generator reading randomness from source,
+// e.g. a seeded one in tests.
+func NewRandomFrom(source io.Reader) *Random {
+	return &Random{source: source}
+}
+
+func (r *Random) Generate(_ context.Context, length int) (string, error) {
+	const op = "alias.Random.Generate"
+
+	res := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(res) < length {
+		if _, err := io.ReadFull(r.source, buf); err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, b := range buf {
+			// bytes above the largest multiple of 62 would bias the result
+			if int(b) >= 256/len(base62Alphabet)*len(base62Alphabet) {
+				continue
+			}
+
+			res = append(res, base62Alphabet[int(b)%len(base62Alphabet)])
+			if len(res) == length {
+				break
+			}
+		}
+	}
+
+	return string(res), nil
+}
@@ -0,0 +1,27 @@
+package alias
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sequential generates base62 encoded ids from a sequence, padded up to
+// length. Aliases are as short as possible but easy to enumerate.
+type Sequential struct {
+	seq Sequence
+}
+
+func NewSequential(seq Sequence) *Sequential {
+	return &Sequential{seq: seq}
+}
+
+func (s *Sequential) Generate(ctx context.Context, length int) (string, error) {
+	const op = "alias.Sequential.Generate"
+
+	id, err := s.seq.NextAliasID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return encode(uint64(id), base62Alphabet, length), nil
+}
@@ -0,0 +1,77 @@
+package alias
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+var adjectives = []string{
+	"agile", "amber", "bold", "brave", "breezy", "bright", "calm", "clever",
+	"cosmic", "crisp", "curious", "daring", "eager", "fancy", "fearless", "fluffy",
+	"gentle", "giant", "glad", "golden", "happy", "hidden", "humble", "jolly",
+	"keen", "kind", "lively", "lucky", "mellow", "merry", "mighty", "misty",
+	"noble", "polite", "proud", "quick", "quiet", "rapid", "rosy", "rusty",
+	"shiny", "silent", "silly", "sleepy", "snowy", "sunny", "swift", "tiny",
+	"witty", "zesty",
+}
+
+var animals = []string{
+	"badger", "beaver", "bison", "camel", "cheetah", "cobra", "coyote", "crane",
+	"dingo", "dolphin", "eagle", "falcon", "ferret", "finch", "fox", "gecko",
+	"gopher", "heron", "hippo", "husky", "ibis", "jaguar", "koala", "lemur",
+	"lion", "llama", "lynx", "marten", "moose", "narwhal", "ocelot", "otter",
+	"owl", "panda", "parrot", "puffin", "quokka", "rabbit", "raven", "salmon",
+	"seal", "sloth", "stork", "tapir", "tiger", "toucan", "walrus", "wombat",
+	"yak", "zebra",
+}
+
+// Words generates human-readable aliases like "brave-otter-42".
+// The number has length-4 digits but at least one, so longer
+// aliases still make collisions rarer.
+type Words struct {
+	source io.Reader
+}
+
+func NewWords() *Words {
+	return &Words{source: rand.Reader}
+}
+
+func (w *Words) Generate(_ context.Context, length int) (string, error) {
+	const op = "alias.Words.Generate"
+
+	// int64 holds up to 18 digits
+	digits := min(max(length-4, 1), 18)
+	limit := int64(1)
+	for i := 0; i < digits; i++ {
+		limit *= 10
+	}
+
+	adjective, err := w.intn(int64(len(adjectives)))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	animal, err := w.intn(int64(len(animals)))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	number, err := w.intn(limit)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return fmt.Sprintf("%s-%s-%d", adjectives[adjective], animals[animal], number), nil
+}
+
+func (w *Words) intn(n int64) (int64, error) {
+	res, err := rand.Int(w.source, big.NewInt(n))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.Int64(), nil
+}
@@ -10,7 +10,8 @@ var (
 	ErrInvalidStatusCode = errors.New("invalid status code")
 )
 
-// GetRedirect returns the final URL after redirection
+// GetRedirect returns the URL a short link redirects to.
+// Any of 301, 302, 307 and 308 statuses is accepted.
 func GetRedirect(url string) (string, error) {
 	const op = "api.GetRedirect"
 
@@ -25,7 +26,9 @@ func GetRedirect(url string) (string, error) {
 		return "", err
 	}
 
-	if resp.StatusCode != http.StatusFound {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
 		return "", fmt.Errorf("%s: %w: %s", op, ErrInvalidStatusCode, resp.Status)
 	}
 
@@ -0,0 +1,60 @@
+// Package apikey generates keys machine clients authenticate with.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// Prefix starts every key, so keys are told apart from user tokens and
+// are easy to find in leaked logs.
+const Prefix = "us_"
+
+const (
+	// ScopeURLsRead allows listing urls and reading their statistics.
+	ScopeURLsRead = "urls:read"
+	// ScopeURLsWrite allows saving, changing and deleting urls.
+	ScopeURLsWrite = "urls:write"
+)
+
+const (
+	// secretSize is how many random bytes a key has.
+	secretSize = 32
+	// shownSize is how many chars of a key are stored as its prefix.
+	shownSize = len(Prefix) + 8
+)
+
+// Generate returns new random key, the prefix shown to tell it apart
+// from other keys and the hash it's stored by.
+func Generate() (key, shown, hash string, err error) {
+	secret := make([]byte, secretSize)
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	key = Prefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, key[:shownSize], Hash(key), nil
+}
+
+// Hash returns hash key is stored by. Keys are random, so unlike
+// passwords they don't need a slow hash.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// IsKey reports whether s looks like a key made by Generate.
+func IsKey(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// HasScope reports whether scopes allow scope.
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope)
+}
@@ -0,0 +1,25 @@
+package apikey_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"url-shortener/internal/lib/apikey"
+)
+
+func TestGenerate(t *testing.T) {
+	key, shown, hash, err := apikey.Generate()
+	require.NoError(t, err)
+	require.True(t, apikey.IsKey(key))
+	require.True(t, strings.HasPrefix(key, shown))
+	require.Len(t, shown, len(apikey.Prefix)+8)
+	require.Equal(t, apikey.Hash(key), hash)
+	require.NotContains(t, hash, key)
+
+	other, _, otherHash, err := apikey.Generate()
+	require.NoError(t, err)
+	require.NotEqual(t, key, other)
+	require.NotEqual(t, hash, otherHash)
+
+	require.False(t, apikey.IsKey("eyJhbGciOiJIUzI1NiJ9"))
+}
@@ -0,0 +1,424 @@
+// Package backup writes urls to and reads them from CSV and NDJSON,
+// so links can be moved between deployments and read outside the database.
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"strconv"
+	"time"
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/storage"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// DefaultConflictPolicy is used on import unless another is chosen,
+// so urls are never overwritten by accident.
+const DefaultConflictPolicy = storage.ConflictFail
+
+// exportPageSize is how many urls are read from storage at once.
+const exportPageSize = 500
+
+var (
+	ErrUnknownFormat = errors.New("unknown format")
+	ErrInvalidRecord = errors.New("invalid record")
+	ErrUnknownPolicy = errors.New("unknown conflict policy")
+)
+
+// columns of CSV in order they are exported. Only alias and url are
+// required on import, so lists from other shorteners can be imported.
+var columns = []string{"alias", "url", "user_id", "created_at", "expires_at", "clicks", "redirect_type", "password_hash", "max_clicks", "used_clicks", "title", "preview", "domain"}
+
+// Record is a url as it's written to NDJSON.
+type Record struct {
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	UserID    int64      `json:"user_id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Clicks    int64      `json:"clicks,omitempty"`
+	// RedirectType is HTTP status of redirects, zero for the server default.
+	RedirectType int `json:"redirect_type,omitempty"`
+	// PasswordHash is bcrypt hash of password protecting the url.
+	PasswordHash string `json:"password_hash,omitempty"`
+	// MaxClicks limits redirects of the url, zero for unlimited.
+	MaxClicks  int64  `json:"max_clicks,omitempty"`
+	UsedClicks int64  `json:"used_clicks,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Preview    bool   `json:"preview,omitempty"`
+	// Domain is short domain of the url, empty for the primary one.
+	Domain string `json:"domain,omitempty"`
+}
+
+// URLExporter returns pages of urls ordered by id.
+type URLExporter interface {
+	ExportURLs(ctx context.Context, filter storage.ExportFilter) ([]storage.URL, error)
+}
+
+// ContentType returns MIME type of format.
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv"
+	}
+
+	return "application/x-ndjson"
+}
+
+// ParseConflictPolicy returns conflict policy named by s.
+func ParseConflictPolicy(s string) (storage.ConflictPolicy, error) {
+	switch policy := storage.ConflictPolicy(s); policy {
+	case storage.ConflictSkip, storage.ConflictOverwrite, storage.ConflictFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnknownPolicy, s)
+	}
+}
+
+// Export writes urls of all users, or of one user if userID is set,
+// to w in given format. It returns the number of written urls.
+func Export(ctx context.Context, exporter URLExporter, userID *int64, format string, w io.Writer) (int, error) {
+	const op = "backup.Export"
+
+	enc, err := newEncoder(format, w)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	written := 0
+	filter := storage.ExportFilter{UserID: userID, Limit: exportPageSize}
+	for {
+		urls, err := exporter.ExportURLs(ctx, filter)
+		if err != nil {
+			return written, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, u := range urls {
+			if err = enc.encode(u); err != nil {
+				return written, fmt.Errorf("%s: %w", op, err)
+			}
+			written++
+		}
+
+		if len(urls) < filter.Limit {
+			break
+		}
+		filter.AfterID = urls[len(urls)-1].ID
+	}
+
+	if err = enc.flush(); err != nil {
+		return written, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return written, nil
+}
+
+// Decode reads urls in given format from r. Urls without owner are given
+// to defaultUserID, urls without creation time are created at now. Urls
+// on domains that are not in domains are rejected.
+func Decode(r io.Reader, format string, defaultUserID int64, domains domain.Allowlist, now time.Time) ([]storage.URL, error) {
+	const op = "backup.Decode"
+
+	var (
+		records []Record
+		err     error
+	)
+
+	switch format {
+	case FormatCSV:
+		records, err = decodeCSV(r)
+	case FormatNDJSON:
+		records, err = decodeNDJSON(r)
+	default:
+		err = fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	validate := validator.New()
+
+	urls := make([]storage.URL, 0, len(records))
+	for i, rec := range records {
+		if rec.Alias == "" || rec.URL == "" {
+			return nil, fmt.Errorf("%s: record %d: %w: alias and url are required", op, i+1, ErrInvalidRecord)
+		}
+		if err = alias.Validate(rec.Alias); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w: %s", op, i+1, ErrInvalidRecord, err)
+		}
+		if err = validate.Var(rec.URL, "url"); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w: url is not valid", op, i+1, ErrInvalidRecord)
+		}
+		if err = validate.Var(rec.RedirectType, "omitempty,oneof=301 302 307 308"); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w: redirect_type is not valid", op, i+1, ErrInvalidRecord)
+		}
+		if rec.PasswordHash != "" && !password.IsHash(rec.PasswordHash) {
+			return nil, fmt.Errorf("%s: record %d: %w: password_hash is not a bcrypt hash", op, i+1, ErrInvalidRecord)
+		}
+		if rec.MaxClicks < 0 || rec.UsedClicks < 0 {
+			return nil, fmt.Errorf("%s: record %d: %w: max_clicks and used_clicks can't be negative", op, i+1, ErrInvalidRecord)
+		}
+		if !domains.Allows(rec.Domain) {
+			return nil, fmt.Errorf("%s: record %d: %w: domain is not allowed", op, i+1, ErrInvalidRecord)
+		}
+
+		u := storage.URL{
+			Alias:        rec.Alias,
+			URL:          rec.URL,
+			UserID:       rec.UserID,
+			CreatedAt:    now,
+			ExpiresAt:    rec.ExpiresAt,
+			Clicks:       rec.Clicks,
+			RedirectType: rec.RedirectType,
+			PasswordHash: rec.PasswordHash,
+			MaxClicks:    rec.MaxClicks,
+			UsedClicks:   rec.UsedClicks,
+			Title:        rec.Title,
+			Preview:      rec.Preview,
+			Domain:       domain.Normalize(rec.Domain),
+		}
+		if u.UserID == 0 {
+			u.UserID = defaultUserID
+		}
+		if rec.CreatedAt != nil {
+			u.CreatedAt = *rec.CreatedAt
+		}
+
+		urls = append(urls, u)
+	}
+
+	return urls, nil
+}
+
+type encoder interface {
+	encode(u storage.URL) error
+	flush() error
+}
+
+func newEncoder(format string, w io.Writer) (encoder, error) {
+	switch format {
+	case FormatCSV:
+		enc := &csvEncoder{w: csv.NewWriter(w)}
+
+		return enc, enc.w.Write(columns)
+	case FormatNDJSON:
+		return &ndjsonEncoder{w: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) encode(u storage.URL) error {
+	expiresAt := ""
+	if u.ExpiresAt != nil {
+		expiresAt = u.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	redirectType := ""
+	if u.RedirectType != 0 {
+		redirectType = strconv.Itoa(u.RedirectType)
+	}
+
+	maxClicks := ""
+	if u.MaxClicks != 0 {
+		maxClicks = strconv.FormatInt(u.MaxClicks, 10)
+	}
+
+	return e.w.Write([]string{
+		u.Alias,
+		u.URL,
+		strconv.FormatInt(u.UserID, 10),
+		u.CreatedAt.UTC().Format(time.RFC3339),
+		expiresAt,
+		strconv.FormatInt(u.Clicks, 10),
+		redirectType,
+		u.PasswordHash,
+		maxClicks,
+		strconv.FormatInt(u.UsedClicks, 10),
+		u.Title,
+		strconv.FormatBool(u.Preview),
+		u.Domain,
+	})
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	w *json.Encoder
+}
+
+func (e *ndjsonEncoder) encode(u storage.URL) error {
+	createdAt := u.CreatedAt.UTC()
+
+	return e.w.Encode(Record{
+		Alias:        u.Alias,
+		URL:          u.URL,
+		UserID:       u.UserID,
+		CreatedAt:    &createdAt,
+		ExpiresAt:    u.ExpiresAt,
+		Clicks:       u.Clicks,
+		RedirectType: u.RedirectType,
+		PasswordHash: u.PasswordHash,
+		MaxClicks:    u.MaxClicks,
+		UsedClicks:   u.UsedClicks,
+		Title:        u.Title,
+		Preview:      u.Preview,
+		Domain:       u.Domain,
+	})
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return nil
+}
+
+func decodeCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	// rows may omit optional columns
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+	for _, name := range []string{"alias", "url"} {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("%w: header has no %s column", ErrInvalidRecord, name)
+		}
+	}
+
+	var records []Record
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return row[i]
+		}
+
+		rec, err := parseCSVRecord(field)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w: %w", line, ErrInvalidRecord, err)
+		}
+
+		records = append(records, rec)
+	}
+}
+
+func parseCSVRecord(field func(name string) string) (Record, error) {
+	rec := Record{
+		Alias:        field("alias"),
+		URL:          field("url"),
+		PasswordHash: field("password_hash"),
+		Title:        field("title"),
+		Domain:       field("domain"),
+	}
+
+	var err error
+	if v := field("user_id"); v != "" {
+		if rec.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return Record{}, err
+		}
+	}
+	if v := field("clicks"); v != "" {
+		if rec.Clicks, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return Record{}, err
+		}
+	}
+	if v := field("max_clicks"); v != "" {
+		if rec.MaxClicks, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return Record{}, err
+		}
+	}
+	if v := field("used_clicks"); v != "" {
+		if rec.UsedClicks, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return Record{}, err
+		}
+	}
+	if v := field("preview"); v != "" {
+		if rec.Preview, err = strconv.ParseBool(v); err != nil {
+			return Record{}, err
+		}
+	}
+	if v := field("redirect_type"); v != "" {
+		if rec.RedirectType, err = strconv.Atoi(v); err != nil {
+			return Record{}, err
+		}
+	}
+	if v := field("created_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return Record{}, err
+		}
+		rec.CreatedAt = &t
+	}
+	if v := field("expires_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return Record{}, err
+		}
+		rec.ExpiresAt = &t
+	}
+
+	return rec, nil
+}
+
+func decodeNDJSON(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w: %w", line, ErrInvalidRecord, err)
+		}
+
+		records = append(records, rec)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
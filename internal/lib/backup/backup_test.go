@@ -0,0 +1,193 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/lib/backup"
+	"url-shortener/internal/lib/domain"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+)
+
+func TestExportDecode(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	expiresAt := now.Add(24 * time.Hour)
+
+	src := memory.New()
+	// more than one page of export
+	for i := 0; i < 1200; i++ {
+		_, err := src.ImportURLs(ctx, []storage.URL{{
+			Alias:        "alias" + strconv.Itoa(i),
+			URL:          "https://example.com/" + strconv.Itoa(i),
+			UserID:       int64(i%2 + 1),
+			CreatedAt:    now,
+			ExpiresAt:    &expiresAt,
+			Clicks:       int64(i),
+			RedirectType: 301,
+			MaxClicks:    int64(i + 10),
+			UsedClicks:   int64(i),
+			Title:        "Example, \"quoted\"",
+			Preview:      true,
+			Domain:       "go.example",
+		}}, storage.ConflictFail)
+		require.NoError(t, err)
+	}
+
+	for _, format := range []string{backup.FormatCSV, backup.FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			userID := int64(2)
+
+			var buf bytes.Buffer
+			written, err := backup.Export(ctx, src, &userID, format, &buf)
+			require.NoError(t, err)
+			require.Equal(t, 600, written)
+
+			urls, err := backup.Decode(&buf, format, 0, domain.NewAllowlist([]string{"go.example"}), time.Now())
+			require.NoError(t, err)
+			require.Len(t, urls, 600)
+
+			require.Equal(t, "alias1", urls[0].Alias)
+			require.Equal(t, "https://example.com/1", urls[0].URL)
+			require.Equal(t, userID, urls[0].UserID)
+			require.True(t, now.Equal(urls[0].CreatedAt))
+			require.NotNil(t, urls[0].ExpiresAt)
+			require.True(t, expiresAt.Equal(*urls[0].ExpiresAt))
+			require.Equal(t, int64(1), urls[0].Clicks)
+			require.Equal(t, 301, urls[0].RedirectType)
+			require.Equal(t, int64(11), urls[0].MaxClicks)
+			require.Equal(t, int64(1), urls[0].UsedClicks)
+			require.Equal(t, "Example, \"quoted\"", urls[0].Title)
+			require.True(t, urls[0].Preview)
+			require.Equal(t, "go.example", urls[0].Domain)
+			require.Equal(t, "alias1199", urls[599].Alias)
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		format string
+		input  string
+		urls   []storage.URL
+		err    error
+	}{
+		{
+			name:   "CSV with required columns only",
+			format: backup.FormatCSV,
+			input:  "url,alias\nhttps://google.com,google\n",
+			urls: []storage.URL{
+				{Alias: "google", URL: "https://google.com", UserID: 42, CreatedAt: now},
+			},
+		},
+		{
+			name:   "NDJSON with empty lines",
+			format: backup.FormatNDJSON,
+			input:  "{\"alias\": \"google\", \"url\": \"https://google.com\", \"user_id\": 7}\n\n",
+			urls: []storage.URL{
+				{Alias: "google", URL: "https://google.com", UserID: 7, CreatedAt: now},
+			},
+		},
+		{
+			name:   "CSV without alias column",
+			format: backup.FormatCSV,
+			input:  "url\nhttps://google.com\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "Invalid CSV field",
+			format: backup.FormatCSV,
+			input:  "alias,url,clicks\ngoogle,https://google.com,many\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "CSV with redirect_type",
+			format: backup.FormatCSV,
+			input:  "alias,url,redirect_type\ngoogle,https://google.com,308\nya,https://ya.ru,\n",
+			urls: []storage.URL{
+				{Alias: "google", URL: "https://google.com", UserID: 42, CreatedAt: now, RedirectType: 308},
+				{Alias: "ya", URL: "https://ya.ru", UserID: 42, CreatedAt: now},
+			},
+		},
+		{
+			name:   "Invalid redirect_type",
+			format: backup.FormatNDJSON,
+			input:  "{\"alias\": \"google\", \"url\": \"https://google.com\", \"redirect_type\": 303}\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "Alias of preview page",
+			format: backup.FormatCSV,
+			input:  "alias,url\ngoogle+,https://google.com\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "Reserved alias",
+			format: backup.FormatNDJSON,
+			input:  "{\"alias\": \"healthz\", \"url\": \"https://google.com\"}\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "Domain",
+			format: backup.FormatCSV,
+			input:  "alias,url,domain\ngoogle,https://google.com,Go.Example\n",
+			urls: []storage.URL{
+				{Alias: "google", URL: "https://google.com", UserID: 42, CreatedAt: now, Domain: "go.example"},
+			},
+		},
+		{
+			name:   "Domain not allowed",
+			format: backup.FormatNDJSON,
+			input:  "{\"alias\": \"google\", \"url\": \"https://google.com\", \"domain\": \"other.example\"}\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "Negative max_clicks",
+			format: backup.FormatNDJSON,
+			input:  "{\"alias\": \"google\", \"url\": \"https://google.com\", \"max_clicks\": -1}\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "Plain password instead of hash",
+			format: backup.FormatCSV,
+			input:  "alias,url,password_hash\ngoogle,https://google.com,secret\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "Invalid URL",
+			format: backup.FormatNDJSON,
+			input:  "{\"alias\": \"google\", \"url\": \"google\"}\n",
+			err:    backup.ErrInvalidRecord,
+		},
+		{
+			name:   "Unknown format",
+			format: "xml",
+			err:    backup.ErrUnknownFormat,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urls, err := backup.Decode(strings.NewReader(tc.input), tc.format, 42, domain.NewAllowlist([]string{"go.example"}), now)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.urls, urls)
+		})
+	}
+}
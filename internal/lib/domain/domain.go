@@ -0,0 +1,55 @@
+// Package domain resolves short domains urls are served on.
+package domain
+
+import (
+	"net"
+	"strings"
+)
+
+// Allowlist is a set of short domains besides the primary one.
+// The primary domain is the empty string, it's always allowed.
+type Allowlist map[string]struct{}
+
+// NewAllowlist returns allowlist of domains, they are compared case
+// insensitively.
+func NewAllowlist(domains []string) Allowlist {
+	list := make(Allowlist, len(domains))
+	for _, d := range domains {
+		if d = Normalize(d); d != "" {
+			list[d] = struct{}{}
+		}
+	}
+
+	return list
+}
+
+// Allows reports whether urls may be saved on domain d.
+func (l Allowlist) Allows(d string) bool {
+	if d == "" {
+		return true
+	}
+
+	_, ok := l[Normalize(d)]
+
+	return ok
+}
+
+// FromHost returns domain of request with Host header host, or the
+// primary domain if host isn't in the allowlist.
+func (l Allowlist) FromHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = Normalize(host)
+	if _, ok := l[host]; !ok {
+		return ""
+	}
+
+	return host
+}
+
+// Normalize returns d the way it's stored: lowercase, without trailing dot.
+func Normalize(d string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+}
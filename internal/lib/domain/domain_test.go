@@ -0,0 +1,40 @@
+package domain_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"url-shortener/internal/lib/domain"
+)
+
+func TestAllowlist(t *testing.T) {
+	list := domain.NewAllowlist([]string{"Go.Example", "s.example.", ""})
+
+	cases := []struct {
+		name     string
+		host     string
+		expected string
+	}{
+		{name: "Listed", host: "go.example", expected: "go.example"},
+		{name: "With port", host: "go.example:8080", expected: "go.example"},
+		{name: "Upper case", host: "S.EXAMPLE", expected: "s.example"},
+		{name: "Trailing dot", host: "s.example.", expected: "s.example"},
+		{name: "Primary", host: "localhost:8082", expected: ""},
+		{name: "IPv6", host: "[::1]:8082", expected: ""},
+		{name: "Empty", host: "", expected: ""},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.expected, list.FromHost(tc.host))
+		})
+	}
+
+	require.True(t, list.Allows(""))
+	require.True(t, list.Allows("GO.example"))
+	require.False(t, list.Allows("other.example"))
+	require.False(t, domain.NewAllowlist(nil).Allows("go.example"))
+}
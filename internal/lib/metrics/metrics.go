@@ -0,0 +1,88 @@
+// Package metrics holds prometheus collectors of the service.
+// They are registered in the default registry served by Handler.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "url_shortener"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of handled http requests.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of handling http requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+var (
+	StorageOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of storage operations.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	StorageOperationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_errors_total",
+		Help:      "Number of failed storage operations. Not found and conflicts are not failures.",
+	}, []string{"operation"})
+)
+
+var (
+	SSORequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sso",
+		Name:      "requests_total",
+		Help:      "Number of grpc calls to sso service.",
+	}, []string{"method", "code"})
+
+	SSORequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sso",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of grpc calls to sso service including retries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+var (
+	LinksCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "links_created_total",
+		Help:      "Number of created short links.",
+	})
+
+	RedirectsServed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_served_total",
+		Help:      "Number of redirects to original urls.",
+	})
+
+	RedirectsNotFound = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_not_found_total",
+		Help:      "Number of redirect requests for unknown aliases.",
+	})
+)
+
+// Handler serves metrics in prometheus format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
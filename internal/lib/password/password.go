@@ -0,0 +1,38 @@
+// Package password hashes passwords protecting links.
+package password
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxLength is the longest password bcrypt accepts, in bytes.
+const maxLength = 72
+
+var ErrTooLong = errors.New("password must be at most 72 bytes long")
+
+// Hash returns bcrypt hash of password.
+func Hash(password string) (string, error) {
+	if len(password) > maxLength {
+		return "", ErrTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// IsHash reports whether s is a hash made by Hash.
+func IsHash(s string) bool {
+	_, err := bcrypt.Cost([]byte(s))
+
+	return err == nil
+}
+
+// Matches reports whether password is the one hash was made of.
+func Matches(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,23 @@
+package password_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"url-shortener/internal/lib/password"
+)
+
+func TestHash(t *testing.T) {
+	hash, err := password.Hash("secret")
+	require.NoError(t, err)
+	require.NotEqual(t, "secret", hash)
+	require.True(t, password.IsHash(hash))
+	require.False(t, password.IsHash("secret"))
+
+	require.True(t, password.Matches(hash, "secret"))
+	require.False(t, password.Matches(hash, "Secret"))
+	require.False(t, password.Matches("not a hash", "secret"))
+
+	_, err = password.Hash(strings.Repeat("a", 73))
+	require.ErrorIs(t, err, password.ErrTooLong)
+}
@@ -0,0 +1,95 @@
+// Package tracing sets up OpenTelemetry tracing and starts spans
+// with the tracer of the service.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "url-shortener"
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// NewExporter returns span exporter of given kind. Endpoint is the address
+// of otlp grpc collector. It returns nil exporter for ExporterNone.
+func NewExporter(ctx context.Context, kind string, endpoint string) (sdktrace.SpanExporter, error) {
+	const op = "tracing.NewExporter"
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch kind {
+	case ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterOTLP:
+		exporter, err = otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpoint(endpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+	default:
+		return nil, fmt.Errorf("%s: unknown exporter %q", op, kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return exporter, nil
+}
+
+// Setup installs global tracer provider exporting sampleRatio of traces
+// with exporter, and W3C trace context propagator. Spans are not recorded
+// if exporter is nil. Provider must be shut down to flush buffered spans.
+func Setup(exporter sdktrace.SpanExporter, sampleRatio float64) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	}
+
+	if exporter != nil {
+		opts = append(opts,
+			sdktrace.WithBatcher(exporter),
+			// traces started by callers follow their sampling decision
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		)
+	} else {
+		opts = append(opts, sdktrace.WithSampler(sdktrace.NeverSample()))
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return tp
+}
+
+// Start starts span with given name as a child of span in ctx.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(serviceName).Start(ctx, name, opts...)
+}
+
+// Fail records err on span and marks span as failed.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,31 @@
+// Package backend opens the storage backend selected by config.
+package backend
+
+import (
+	"fmt"
+	"url-shortener/internal/config"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/sqlite"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+// New opens storage of configured driver and migrates its schema.
+func New(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.Storage.Driver {
+	case DriverSQLite:
+		return sqlite.New(cfg.StoragePath)
+	case DriverPostgres:
+		return postgres.New(cfg.Storage.DSN)
+	case DriverMemory:
+		return memory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
@@ -0,0 +1,218 @@
+// Package instrumented wraps storage.Storage with prometheus metrics and tracing.
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"time"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+)
+
+// Storage observes duration and errors of every operation of wrapped
+// storage and traces it with a span.
+type Storage struct {
+	storage storage.Storage
+}
+
+func New(s storage.Storage) *Storage {
+	return &Storage{storage: s}
+}
+
+func (s *Storage) SaveURL(
+	ctx context.Context,
+	urlToSave string,
+	alias string,
+	userID int64,
+	opts storage.URLOptions,
+) (id int64, err error) {
+	ctx, end := observe(ctx, "save_url")
+	defer func() { end(err) }()
+
+	return s.storage.SaveURL(ctx, urlToSave, alias, userID, opts)
+}
+
+func (s *Storage) SaveURLs(ctx context.Context, userID int64, urls []storage.URLToSave) (results []storage.SaveResult, err error) {
+	ctx, end := observe(ctx, "save_urls")
+	defer func() { end(err) }()
+
+	return s.storage.SaveURLs(ctx, userID, urls)
+}
+
+func (s *Storage) GetURL(ctx context.Context, domain, alias string) (url storage.URL, err error) {
+	ctx, end := observe(ctx, "get_url")
+	defer func() { end(err) }()
+
+	return s.storage.GetURL(ctx, domain, alias)
+}
+
+func (s *Storage) ConsumeClick(ctx context.Context, domain, alias string) (err error) {
+	ctx, end := observe(ctx, "consume_click")
+	defer func() { end(err) }()
+
+	return s.storage.ConsumeClick(ctx, domain, alias)
+}
+
+func (s *Storage) GetURLOwner(ctx context.Context, domain, alias string) (owner int64, err error) {
+	ctx, end := observe(ctx, "get_url_owner")
+	defer func() { end(err) }()
+
+	return s.storage.GetURLOwner(ctx, domain, alias)
+}
+
+func (s *Storage) UpdateURL(ctx context.Context, domain, alias string, upd storage.URLUpdate) (err error) {
+	ctx, end := observe(ctx, "update_url")
+	defer func() { end(err) }()
+
+	return s.storage.UpdateURL(ctx, domain, alias, upd)
+}
+
+func (s *Storage) GetURLInfo(ctx context.Context, domain, alias string) (url storage.URL, err error) {
+	ctx, end := observe(ctx, "get_url_info")
+	defer func() { end(err) }()
+
+	return s.storage.GetURLInfo(ctx, domain, alias)
+}
+
+func (s *Storage) RenameURL(ctx context.Context, domain, alias string, newAlias string) (err error) {
+	ctx, end := observe(ctx, "rename_url")
+	defer func() { end(err) }()
+
+	return s.storage.RenameURL(ctx, domain, alias, newAlias)
+}
+
+func (s *Storage) DeleteURL(ctx context.Context, domain, alias string) (err error) {
+	ctx, end := observe(ctx, "delete_url")
+	defer func() { end(err) }()
+
+	return s.storage.DeleteURL(ctx, domain, alias)
+}
+
+func (s *Storage) ListURLs(
+	ctx context.Context,
+	userID int64,
+	params storage.ListURLsParams,
+) (urls []storage.URL, err error) {
+	ctx, end := observe(ctx, "list_urls")
+	defer func() { end(err) }()
+
+	return s.storage.ListURLs(ctx, userID, params)
+}
+
+func (s *Storage) ExportURLs(ctx context.Context, filter storage.ExportFilter) (urls []storage.URL, err error) {
+	ctx, end := observe(ctx, "export_urls")
+	defer func() { end(err) }()
+
+	return s.storage.ExportURLs(ctx, filter)
+}
+
+func (s *Storage) ImportURLs(
+	ctx context.Context,
+	urls []storage.URL,
+	onConflict storage.ConflictPolicy,
+) (stats storage.ImportStats, err error) {
+	ctx, end := observe(ctx, "import_urls")
+	defer func() { end(err) }()
+
+	return s.storage.ImportURLs(ctx, urls, onConflict)
+}
+
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, before time.Time) (deleted int64, err error) {
+	ctx, end := observe(ctx, "delete_expired_urls")
+	defer func() { end(err) }()
+
+	return s.storage.DeleteExpiredURLs(ctx, before)
+}
+
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) (err error) {
+	ctx, end := observe(ctx, "save_clicks")
+	defer func() { end(err) }()
+
+	return s.storage.SaveClicks(ctx, clicks)
+}
+
+func (s *Storage) GetClickStats(
+	ctx context.Context,
+	domain, alias string,
+	from, to time.Time,
+	bucket time.Duration,
+) (stats storage.ClickStats, err error) {
+	ctx, end := observe(ctx, "get_click_stats")
+	defer func() { end(err) }()
+
+	return s.storage.GetClickStats(ctx, domain, alias, from, to, bucket)
+}
+
+func (s *Storage) SaveAPIKey(ctx context.Context, key storage.APIKey) (id int64, err error) {
+	ctx, end := observe(ctx, "save_api_key")
+	defer func() { end(err) }()
+
+	return s.storage.SaveAPIKey(ctx, key)
+}
+
+func (s *Storage) GetAPIKey(ctx context.Context, hash string) (key storage.APIKey, err error) {
+	ctx, end := observe(ctx, "get_api_key")
+	defer func() { end(err) }()
+
+	return s.storage.GetAPIKey(ctx, hash)
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context, userID int64) (keys []storage.APIKey, err error) {
+	ctx, end := observe(ctx, "list_api_keys")
+	defer func() { end(err) }()
+
+	return s.storage.ListAPIKeys(ctx, userID)
+}
+
+func (s *Storage) DeleteAPIKey(ctx context.Context, userID, id int64) (err error) {
+	ctx, end := observe(ctx, "delete_api_key")
+	defer func() { end(err) }()
+
+	return s.storage.DeleteAPIKey(ctx, userID, id)
+}
+
+func (s *Storage) NextAliasID(ctx context.Context) (id int64, err error) {
+	ctx, end := observe(ctx, "next_alias_id")
+	defer func() { end(err) }()
+
+	return s.storage.NextAliasID(ctx)
+}
+
+func (s *Storage) Ping(ctx context.Context) (err error) {
+	ctx, end := observe(ctx, "ping")
+	defer func() { end(err) }()
+
+	return s.storage.Ping(ctx)
+}
+
+func (s *Storage) Close() error {
+	return s.storage.Close()
+}
+
+// observe starts span and timer of operation. Returned func must be
+// called with the result of operation to finish them.
+func observe(ctx context.Context, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage."+operation)
+
+	return ctx, func(err error) {
+		defer span.End()
+
+		metrics.StorageOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+		if err != nil && !isExpected(err) {
+			metrics.StorageOperationErrors.WithLabelValues(operation).Inc()
+			tracing.Fail(span, err)
+		}
+	}
+}
+
+// isExpected reports whether err is a regular outcome rather than a failure.
+func isExpected(err error) bool {
+	return errors.Is(err, storage.ErrURLNotFound) ||
+		errors.Is(err, storage.ErrURLExists) ||
+		errors.Is(err, storage.ErrURLExpired) ||
+		errors.Is(err, storage.ErrURLExhausted) ||
+		errors.Is(err, storage.ErrAPIKeyNotFound)
+}
@@ -0,0 +1,44 @@
+package instrumented_test
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/instrumented"
+	"url-shortener/internal/storage/memory"
+	"url-shortener/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return instrumented.New(memory.New())
+	})
+}
+
+func TestStorage_ExpectedErrorsAreNotCounted(t *testing.T) {
+	ctx := context.Background()
+	s := instrumented.New(memory.New())
+
+	getErrors := metrics.StorageOperationErrors.WithLabelValues("get_url")
+	consumeErrors := metrics.StorageOperationErrors.WithLabelValues("consume_click")
+	getErrorsBefore := testutil.ToFloat64(getErrors)
+	consumeErrorsBefore := testutil.ToFloat64(consumeErrors)
+
+	_, err := s.GetURL(ctx, "", "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// one-time url that was already opened
+	_, err = s.SaveURL(ctx, "https://google.com", "once", 1, storage.URLOptions{MaxClicks: 1})
+	require.NoError(t, err)
+	require.NoError(t, s.ConsumeClick(ctx, "", "once"))
+
+	require.ErrorIs(t, s.ConsumeClick(ctx, "", "once"), storage.ErrURLExhausted)
+	_, err = s.GetURL(ctx, "", "once")
+	require.ErrorIs(t, err, storage.ErrURLExhausted)
+
+	require.Equal(t, getErrorsBefore, testutil.ToFloat64(getErrors))
+	require.Equal(t, consumeErrorsBefore, testutil.ToFloat64(consumeErrors))
+}
@@ -0,0 +1,614 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+// Storage keeps urls in memory. It's meant for tests and throwaway
+// deployments: everything is lost on restart.
+type Storage struct {
+	mu          sync.RWMutex
+	lastID      int64
+	lastAliasID int64
+	urls        map[key]*storage.URL
+	// exhaustedAt is when urls keyed by id ran out of clicks
+	exhaustedAt map[int64]time.Time
+	clicks      map[int64][]storage.Click
+	lastKeyID   int64
+	// apiKeys are keyed by hash
+	apiKeys map[string]*storage.APIKey
+}
+
+// key identifies url, aliases are unique per domain.
+type key struct {
+	domain string
+	alias  string
+}
+
+func keyOf(u *storage.URL) key {
+	return key{domain: u.Domain, alias: u.Alias}
+}
+
+func New() *Storage {
+	return &Storage{
+		urls:        make(map[key]*storage.URL),
+		exhaustedAt: make(map[int64]time.Time),
+		clicks:      make(map[int64][]storage.Click),
+		apiKeys:     make(map[string]*storage.APIKey),
+	}
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
+
+func (s *Storage) Ping(context.Context) error {
+	return nil
+}
+
+// NextAliasID returns the next value of the sequence ID-based aliases are built from.
+func (s *Storage) NextAliasID(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastAliasID++
+
+	return s.lastAliasID, nil
+}
+
+func (s *Storage) SaveURL(
+	_ context.Context,
+	urlToSave string,
+	alias string,
+	userID int64,
+	opts storage.URLOptions,
+) (int64, error) {
+	const op = "storage.memory.SaveURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{domain: opts.Domain, alias: alias}
+	if _, ok := s.urls[k]; ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	}
+
+	s.lastID++
+	s.urls[k] = &storage.URL{
+		ID:           s.lastID,
+		Domain:       opts.Domain,
+		Alias:        alias,
+		URL:          urlToSave,
+		UserID:       userID,
+		CreatedAt:    time.Now().UTC(),
+		ExpiresAt:    copyTime(opts.ExpiresAt),
+		RedirectType: opts.RedirectType,
+		PasswordHash: opts.PasswordHash,
+		MaxClicks:    opts.MaxClicks,
+		Title:        opts.Title,
+		Preview:      opts.Preview,
+	}
+
+	return s.lastID, nil
+}
+
+func (s *Storage) SaveURLs(_ context.Context, userID int64, urls []storage.URLToSave) ([]storage.SaveResult, error) {
+	const op = "storage.memory.SaveURLs"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]storage.SaveResult, len(urls))
+	for i, u := range urls {
+		k := key{domain: u.Opts.Domain, alias: u.Alias}
+		if _, ok := s.urls[k]; ok {
+			results[i].Err = fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+			continue
+		}
+
+		s.lastID++
+		s.urls[k] = &storage.URL{
+			ID:           s.lastID,
+			Domain:       u.Opts.Domain,
+			Alias:        u.Alias,
+			URL:          u.URL,
+			UserID:       userID,
+			CreatedAt:    time.Now().UTC(),
+			ExpiresAt:    copyTime(u.Opts.ExpiresAt),
+			RedirectType: u.Opts.RedirectType,
+			PasswordHash: u.Opts.PasswordHash,
+			MaxClicks:    u.Opts.MaxClicks,
+			Title:        u.Opts.Title,
+			Preview:      u.Opts.Preview,
+		}
+		results[i].ID = s.lastID
+	}
+
+	return results, nil
+}
+
+// GetURL returns the url with given domain and alias unless it's expired.
+func (s *Storage) GetURL(_ context.Context, domain, alias string) (storage.URL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.urls[key{domain: domain, alias: alias}]
+	if !ok {
+		return storage.URL{}, storage.ErrURLNotFound
+	}
+
+	if isExpired(u, time.Now()) {
+		return storage.URL{}, storage.ErrURLExpired
+	}
+
+	if isExhausted(u) {
+		return storage.URL{}, storage.ErrURLExhausted
+	}
+
+	return copyURL(u), nil
+}
+
+// ConsumeClick uses one of clicks the url with given domain and alias is
+// limited to. It returns storage.ErrURLExhausted if none is left. Urls
+// without limit are left untouched.
+func (s *Storage) ConsumeClick(_ context.Context, domain, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[key{domain: domain, alias: alias}]
+	if !ok {
+		return storage.ErrURLNotFound
+	}
+
+	if u.MaxClicks == 0 {
+		return nil
+	}
+
+	if isExhausted(u) {
+		return storage.ErrURLExhausted
+	}
+
+	u.UsedClicks++
+	if isExhausted(u) {
+		s.exhaustedAt[u.ID] = time.Now()
+	}
+
+	return nil
+}
+
+// UpdateURL applies non-nil fields of upd to the url with given domain and alias.
+func (s *Storage) UpdateURL(_ context.Context, domain, alias string, upd storage.URLUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[key{domain: domain, alias: alias}]
+	if !ok {
+		return storage.ErrURLNotFound
+	}
+
+	if upd.URL != nil {
+		u.URL = *upd.URL
+	}
+
+	switch {
+	case upd.NoExpiry:
+		u.ExpiresAt = nil
+	case upd.ExpiresAt != nil:
+		u.ExpiresAt = copyTime(upd.ExpiresAt)
+	}
+
+	if upd.RedirectType != nil {
+		u.RedirectType = *upd.RedirectType
+	}
+
+	return nil
+}
+
+// GetURLOwner returns id of the user who created the url with given
+// domain and alias.
+func (s *Storage) GetURLOwner(_ context.Context, domain, alias string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.urls[key{domain: domain, alias: alias}]
+	if !ok {
+		return 0, storage.ErrURLNotFound
+	}
+
+	return u.UserID, nil
+}
+
+// GetURLInfo returns the url with given domain and alias, even if it's expired.
+func (s *Storage) GetURLInfo(_ context.Context, domain, alias string) (storage.URL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.urls[key{domain: domain, alias: alias}]
+	if !ok {
+		return storage.URL{}, storage.ErrURLNotFound
+	}
+
+	return copyURL(u), nil
+}
+
+// RenameURL changes alias of the url within its domain. Clicks stay with the url.
+func (s *Storage) RenameURL(_ context.Context, domain, alias string, newAlias string) error {
+	const op = "storage.memory.RenameURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[key{domain: domain, alias: alias}]
+	if !ok {
+		return storage.ErrURLNotFound
+	}
+
+	if _, ok = s.urls[key{domain: domain, alias: newAlias}]; ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	}
+
+	delete(s.urls, keyOf(u))
+	u.Alias = newAlias
+	s.urls[keyOf(u)] = u
+
+	return nil
+}
+
+func (s *Storage) DeleteURL(_ context.Context, domain, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[key{domain: domain, alias: alias}]
+	if !ok {
+		return storage.ErrURLNotFound
+	}
+
+	s.deleteURL(u)
+
+	return nil
+}
+
+// ListURLs returns a page of urls created by the user with given id.
+// Aliases are compared bytewise like in sqlite.
+func (s *Storage) ListURLs(_ context.Context, userID int64, params storage.ListURLsParams) ([]storage.URL, error) {
+	const op = "storage.memory.ListURLs"
+
+	var afterCreatedAt time.Time
+	if params.After != "" && params.SortBy != storage.SortByAlias {
+		createdAt, err := time.Parse(time.RFC3339Nano, params.After)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid cursor: %w", op, err)
+		}
+		afterCreatedAt = createdAt
+	}
+
+	// compare returns -1, 0 or 1 comparing url with cursor
+	compare := func(u *storage.URL) int {
+		return cmp.Or(u.CreatedAt.Compare(afterCreatedAt), cmp.Compare(u.ID, params.AfterID))
+	}
+	less := func(a, b *storage.URL) bool {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID)) < 0
+	}
+	if params.SortBy == storage.SortByAlias {
+		// the same alias may be used on several domains
+		compare = func(u *storage.URL) int {
+			return cmp.Or(cmp.Compare(u.Alias, params.After), cmp.Compare(u.ID, params.AfterID))
+		}
+		less = func(a, b *storage.URL) bool {
+			return cmp.Or(cmp.Compare(a.Alias, b.Alias), cmp.Compare(a.ID, b.ID)) < 0
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []*storage.URL
+	for _, u := range s.urls {
+		if u.UserID != userID {
+			continue
+		}
+
+		if params.After != "" {
+			if c := compare(u); c == 0 || (c < 0) != params.Desc {
+				continue
+			}
+		}
+
+		found = append(found, u)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if params.Desc {
+			return less(found[j], found[i])
+		}
+		return less(found[i], found[j])
+	})
+
+	if len(found) > params.Limit {
+		found = found[:params.Limit]
+	}
+
+	urls := make([]storage.URL, 0, len(found))
+	for _, u := range found {
+		urls = append(urls, copyURL(u))
+	}
+
+	return urls, nil
+}
+
+func (s *Storage) ExportURLs(_ context.Context, filter storage.ExportFilter) ([]storage.URL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []*storage.URL
+	for _, u := range s.urls {
+		if u.ID <= filter.AfterID || (filter.UserID != nil && u.UserID != *filter.UserID) {
+			continue
+		}
+
+		found = append(found, u)
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+
+	if len(found) > filter.Limit {
+		found = found[:filter.Limit]
+	}
+
+	urls := make([]storage.URL, 0, len(found))
+	for _, u := range found {
+		urls = append(urls, copyURL(u))
+	}
+
+	return urls, nil
+}
+
+// ImportURLs saves urls keeping their owners, creation times and click
+// counters. Ids of imported urls are assigned anew.
+func (s *Storage) ImportURLs(_ context.Context, urls []storage.URL, onConflict storage.ConflictPolicy) (storage.ImportStats, error) {
+	const op = "storage.memory.ImportURLs"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// nothing is changed before all conflicts are known
+	if onConflict == storage.ConflictFail {
+		seen := make(map[key]bool, len(urls))
+		for _, u := range urls {
+			if _, ok := s.urls[keyOf(&u)]; ok || seen[keyOf(&u)] {
+				return storage.ImportStats{}, fmt.Errorf("%s: alias %q: %w", op, u.Alias, storage.ErrURLExists)
+			}
+			seen[keyOf(&u)] = true
+		}
+	}
+
+	// used up urls are kept for retention from the import like on this server
+	now := time.Now()
+
+	var stats storage.ImportStats
+	for _, u := range urls {
+		imported := copyURL(&u)
+		imported.CreatedAt = u.CreatedAt.UTC()
+
+		if existing, ok := s.urls[keyOf(&u)]; ok {
+			if onConflict == storage.ConflictSkip {
+				stats.Skipped++
+				continue
+			}
+
+			imported.ID = existing.ID
+			s.urls[keyOf(&u)] = &imported
+			s.setExhaustedAt(&imported, now)
+			stats.Updated++
+			continue
+		}
+
+		s.lastID++
+		imported.ID = s.lastID
+		s.urls[keyOf(&u)] = &imported
+		s.setExhaustedAt(&imported, now)
+		stats.Created++
+	}
+
+	return stats, nil
+}
+
+// DeleteExpiredURLs deletes urls that expired or ran out of clicks before
+// given moment together with their clicks and returns the number of
+// deleted urls.
+func (s *Storage) DeleteExpiredURLs(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for _, u := range s.urls {
+		if isExpired(u, before) || isExhausted(u) && s.exhaustedAt[u.ID].Unix() <= before.Unix() {
+			s.deleteURL(u)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// SaveClicks stores click events and increments click counters of their urls.
+// Clicks on urls that no longer exist are skipped.
+func (s *Storage) SaveClicks(_ context.Context, clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range clicks {
+		u, ok := s.urls[key{domain: c.Domain, alias: c.Alias}]
+		if !ok {
+			continue
+		}
+
+		u.Clicks++
+		s.clicks[u.ID] = append(s.clicks[u.ID], c)
+	}
+
+	return nil
+}
+
+// GetClickStats returns total clicks of the url with given domain and
+// alias and click counts in [from, to) grouped into buckets of given size.
+// Buckets without clicks are omitted.
+func (s *Storage) GetClickStats(
+	_ context.Context,
+	domain, alias string,
+	from, to time.Time,
+	bucket time.Duration,
+) (storage.ClickStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.urls[key{domain: domain, alias: alias}]
+	if !ok {
+		return storage.ClickStats{}, storage.ErrURLNotFound
+	}
+
+	size := int64(bucket.Seconds())
+	counts := make(map[int64]int64)
+	for _, c := range s.clicks[u.ID] {
+		at := c.ClickedAt.Unix()
+		if at < from.Unix() || at >= to.Unix() {
+			continue
+		}
+		counts[at/size*size]++
+	}
+
+	stats := storage.ClickStats{Total: u.Clicks}
+	for start, count := range counts {
+		stats.Buckets = append(stats.Buckets, storage.ClickBucket{
+			Start: time.Unix(start, 0).UTC(),
+			Count: count,
+		})
+	}
+
+	sort.Slice(stats.Buckets, func(i, j int) bool {
+		return stats.Buckets[i].Start.Before(stats.Buckets[j].Start)
+	})
+
+	return stats, nil
+}
+
+// SaveAPIKey saves key of its user and returns its id.
+func (s *Storage) SaveAPIKey(_ context.Context, key storage.APIKey) (int64, error) {
+	const op = "storage.memory.SaveAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apiKeys[key.Hash]; ok {
+		return 0, fmt.Errorf("%s: key hash is taken", op)
+	}
+
+	s.lastKeyID++
+	key.ID = s.lastKeyID
+	key.Scopes = append([]string(nil), key.Scopes...)
+	key.CreatedAt = key.CreatedAt.UTC()
+	s.apiKeys[key.Hash] = &key
+
+	return key.ID, nil
+}
+
+// GetAPIKey returns key with given hash.
+func (s *Storage) GetAPIKey(_ context.Context, hash string) (storage.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.apiKeys[hash]
+	if !ok {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+
+	return copyAPIKey(key), nil
+}
+
+// ListAPIKeys returns keys of user in order they were created.
+func (s *Storage) ListAPIKeys(_ context.Context, userID int64) ([]storage.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]storage.APIKey, 0)
+	for _, key := range s.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// DeleteAPIKey revokes key of user. Keys of other users are not found.
+func (s *Storage) DeleteAPIKey(_ context.Context, userID, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, key := range s.apiKeys {
+		if key.ID == id && key.UserID == userID {
+			delete(s.apiKeys, hash)
+
+			return nil
+		}
+	}
+
+	return storage.ErrAPIKeyNotFound
+}
+
+// deleteURL must be called with write lock held.
+func (s *Storage) deleteURL(u *storage.URL) {
+	delete(s.clicks, u.ID)
+	delete(s.exhaustedAt, u.ID)
+	delete(s.urls, keyOf(u))
+}
+
+// setExhaustedAt records imported url as used up at now if it has no clicks left.
+func (s *Storage) setExhaustedAt(u *storage.URL, now time.Time) {
+	delete(s.exhaustedAt, u.ID)
+	if isExhausted(u) {
+		s.exhaustedAt[u.ID] = now
+	}
+}
+
+func isExpired(u *storage.URL, now time.Time) bool {
+	// compared with seconds precision like in sql storages
+	return u.ExpiresAt != nil && u.ExpiresAt.Unix() <= now.Unix()
+}
+
+func isExhausted(u *storage.URL) bool {
+	return u.MaxClicks > 0 && u.UsedClicks >= u.MaxClicks
+}
+
+func copyURL(u *storage.URL) storage.URL {
+	res := *u
+	res.ExpiresAt = copyTime(u.ExpiresAt)
+
+	return res
+}
+
+func copyAPIKey(key *storage.APIKey) storage.APIKey {
+	res := *key
+	res.Scopes = append([]string(nil), key.Scopes...)
+
+	return res
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	res := t.UTC()
+
+	return &res
+}
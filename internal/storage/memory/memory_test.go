@@ -0,0 +1,52 @@
+package memory_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+	"url-shortener/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return memory.New()
+	})
+}
+
+func TestStorage_Concurrent(t *testing.T) {
+	s := memory.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			alias := "alias" + strconv.Itoa(i%10)
+			_, _ = s.SaveURL(context.Background(), "https://example.com", alias, 1, storage.URLOptions{})
+			_, _ = s.GetURL(context.Background(), "", alias)
+			_ = s.SaveClicks(context.Background(), []storage.Click{{Alias: alias, ClickedAt: time.Now()}})
+			_, _ = s.ListURLs(context.Background(), 1, storage.ListURLsParams{SortBy: storage.SortByAlias, Limit: 5})
+			if i%3 == 0 {
+				_ = s.DeleteURL(context.Background(), "", alias)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// ids stay unique under concurrent inserts
+	urls, err := s.ListURLs(context.Background(), 1, storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Limit: 100})
+	require.NoError(t, err)
+
+	ids := make(map[int64]struct{})
+	for _, u := range urls {
+		_, dup := ids[u.ID]
+		require.False(t, dup)
+		ids[u.ID] = struct{}{}
+	}
+}
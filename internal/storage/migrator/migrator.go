@@ -0,0 +1,272 @@
+// Package migrator applies versioned sql migrations.
+//
+// Migrations are read from files named NNNN_description.up.sql and
+// NNNN_description.down.sql, where NNNN is the version. Applied versions
+// are recorded in the schema_migrations table. Every migration runs in its
+// own transaction together with the version bookkeeping.
+package migrator
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+var (
+	ErrInvalidMigrations = errors.New("invalid migrations")
+)
+
+var fileNameRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New returns migrator applying migrations from the root of fsys.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	const op = "migrator.New"
+
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+// Version returns the latest applied version, 0 if none.
+func (m *Migrator) Version() (int, error) {
+	const op = "migrator.Version"
+
+	if err := m.ensureTable(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var version sql.NullInt64
+	err := m.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(version.Int64), nil
+}
+
+// Latest returns the version of the newest known migration.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up() (int, error) {
+	const op = "migrator.Up"
+
+	current, err := m.Version()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	applied := 0
+	for _, mg := range m.migrations {
+		if mg.Version <= current {
+			continue
+		}
+
+		err = m.apply(mg.Up, "INSERT INTO schema_migrations(version) VALUES("+m.placeholder(1)+")", mg.Version)
+		if err != nil {
+			return applied, fmt.Errorf("%s: migration %04d_%s: %w", op, mg.Version, mg.Name, err)
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// Baseline records known migrations up to version as applied without
+// running them. It's meant for databases whose schema was created
+// before migrations and already matches that version.
+func (m *Migrator) Baseline(version int) error {
+	const op = "migrator.Baseline"
+
+	current, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, mg := range m.migrations {
+		if mg.Version > version {
+			break
+		}
+		if mg.Version <= current {
+			continue
+		}
+
+		_, err = tx.Exec("INSERT INTO schema_migrations(version) VALUES("+m.placeholder(1)+")", mg.Version)
+		if err != nil {
+			return fmt.Errorf("%s: migration %04d_%s: %w", op, mg.Version, mg.Name, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// Down rolls back up to steps latest applied migrations
+// and returns how many were rolled back.
+func (m *Migrator) Down(steps int) (int, error) {
+	const op = "migrator.Down"
+
+	current, err := m.Version()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rolledBack := 0
+	for i := len(m.migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+		mg := m.migrations[i]
+		if mg.Version > current {
+			continue
+		}
+
+		err = m.apply(mg.Down, "DELETE FROM schema_migrations WHERE version = "+m.placeholder(1), mg.Version)
+		if err != nil {
+			return rolledBack, fmt.Errorf("%s: migration %04d_%s: %w", op, mg.Version, mg.Name, err)
+		}
+		rolledBack++
+	}
+
+	return rolledBack, nil
+}
+
+func (m *Migrator) apply(migration string, bookkeeping string, version int) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.Exec(migration); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(bookkeeping, version); err != nil {
+		return fmt.Errorf("update schema_migrations: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+	    version INTEGER PRIMARY KEY,
+	    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`)
+
+	return err
+}
+
+func (m *Migrator) placeholder(n int) string {
+	if m.dialect == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		match := fileNameRe.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: unexpected file %s", ErrInvalidMigrations, e.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		if version == 0 {
+			return nil, fmt.Errorf("%w: version must be positive in %s", ErrInvalidMigrations, e.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mg
+		}
+		if mg.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d has different names", ErrInvalidMigrations, version)
+		}
+
+		if match[3] == "up" {
+			mg.Up = string(body)
+		} else {
+			mg.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("%w: version %d needs both up and down files", ErrInvalidMigrations, mg.Version)
+		}
+		migrations = append(migrations, *mg)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
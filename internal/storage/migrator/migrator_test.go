@@ -0,0 +1,173 @@
+package migrator_test
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"url-shortener/internal/storage/migrator"
+)
+
+func TestMigrator(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	fsys := fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a(id INTEGER);")},
+		"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b(id INTEGER); CREATE TABLE c(id INTEGER);")},
+		"0002_create_b.down.sql": {Data: []byte("DROP TABLE c; DROP TABLE b;")},
+	}
+
+	m, err := migrator.New(db, migrator.SQLite, fsys)
+	require.NoError(t, err)
+	require.Equal(t, 2, m.Latest())
+
+	version, err := m.Version()
+	require.NoError(t, err)
+	require.Zero(t, version)
+
+	applied, err := m.Up()
+	require.NoError(t, err)
+	require.Equal(t, 2, applied)
+	requireTables(t, db, "a", "b", "c")
+
+	// nothing pending
+	applied, err = m.Up()
+	require.NoError(t, err)
+	require.Zero(t, applied)
+
+	rolledBack, err := m.Down(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, rolledBack)
+	requireTables(t, db, "a")
+
+	version, err = m.Version()
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+
+	rolledBack, err = m.Down(5)
+	require.NoError(t, err)
+	require.Equal(t, 1, rolledBack)
+	requireTables(t, db)
+}
+
+func TestMigrator_Baseline(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	// schema of the first migration created without migrator
+	_, err = db.Exec("CREATE TABLE a(id INTEGER);")
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a(id INTEGER);")},
+		"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b(id INTEGER);")},
+		"0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	}
+
+	m, err := migrator.New(db, migrator.SQLite, fsys)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Baseline(1))
+
+	version, err := m.Version()
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+
+	applied, err := m.Up()
+	require.NoError(t, err)
+	require.Equal(t, 1, applied)
+	requireTables(t, db, "a", "b")
+}
+
+func TestMigrator_FailedMigrationIsRolledBack(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	fsys := fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a(id INTEGER);")},
+		"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"0002_broken.up.sql":     {Data: []byte("CREATE TABLE b(id INTEGER); CREATE TABLE a(id INTEGER);")},
+		"0002_broken.down.sql":   {Data: []byte("DROP TABLE b;")},
+	}
+
+	m, err := migrator.New(db, migrator.SQLite, fsys)
+	require.NoError(t, err)
+
+	applied, err := m.Up()
+	require.Error(t, err)
+	require.Equal(t, 1, applied)
+	requireTables(t, db, "a")
+
+	version, err := m.Version()
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+}
+
+func TestNew_InvalidMigrations(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "Unexpected file",
+			fsys: fstest.MapFS{"create.sql": {}},
+		},
+		{
+			name: "Missing down",
+			fsys: fstest.MapFS{"0001_create.up.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "Zero version",
+			fsys: fstest.MapFS{
+				"0000_create.up.sql":   {Data: []byte("SELECT 1;")},
+				"0000_create.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "Different names",
+			fsys: fstest.MapFS{
+				"0001_create.up.sql": {Data: []byte("SELECT 1;")},
+				"0001_drop.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := migrator.New(nil, migrator.SQLite, tt.fsys)
+			require.ErrorIs(t, err, migrator.ErrInvalidMigrations)
+		})
+	}
+}
+
+func requireTables(t *testing.T, db *sql.DB, want ...string) {
+	t.Helper()
+
+	rows, err := db.Query(`
+	SELECT name FROM sqlite_master
+	WHERE type = 'table' AND name != 'schema_migrations'
+	ORDER BY name`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	got := []string{}
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		got = append(got, name)
+	}
+	require.NoError(t, rows.Err())
+
+	if want == nil {
+		want = []string{}
+	}
+	require.Equal(t, want, got)
+}
@@ -0,0 +1,2 @@
+DROP TABLE url_click;
+DROP TABLE url;
@@ -0,0 +1,21 @@
+-- Databases created before migrations were introduced already have these
+-- tables, so they're created only if missing.
+CREATE TABLE IF NOT EXISTS url(
+    id BIGSERIAL PRIMARY KEY,
+    alias TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    clicks BIGINT NOT NULL DEFAULT 0,
+    expires_at BIGINT);
+CREATE INDEX IF NOT EXISTS idx_user_id ON url(user_id);
+CREATE INDEX IF NOT EXISTS idx_expires_at ON url(expires_at);
+
+CREATE TABLE IF NOT EXISTS url_click(
+    id BIGSERIAL PRIMARY KEY,
+    url_id BIGINT NOT NULL,
+    clicked_at BIGINT NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_hash TEXT NOT NULL DEFAULT '');
+CREATE INDEX IF NOT EXISTS idx_url_click_url_id_clicked_at ON url_click(url_id, clicked_at);
@@ -0,0 +1 @@
+DROP SEQUENCE alias_sequence;
@@ -0,0 +1 @@
+CREATE SEQUENCE alias_sequence;
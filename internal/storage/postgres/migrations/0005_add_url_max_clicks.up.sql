@@ -0,0 +1,3 @@
+-- max_clicks of 0 means the url is not limited.
+ALTER TABLE url ADD COLUMN max_clicks BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE url ADD COLUMN used_clicks BIGINT NOT NULL DEFAULT 0;
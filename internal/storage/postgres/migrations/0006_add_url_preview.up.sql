@@ -0,0 +1,3 @@
+-- title is shown on preview page, preview shows it before every redirect.
+ALTER TABLE url ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE url ADD COLUMN preview BOOLEAN NOT NULL DEFAULT FALSE;
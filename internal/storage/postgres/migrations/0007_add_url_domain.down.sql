@@ -0,0 +1,4 @@
+-- Fails if an alias is used on several domains.
+ALTER TABLE url DROP CONSTRAINT url_domain_alias_key;
+ALTER TABLE url ADD CONSTRAINT url_alias_key UNIQUE (alias);
+ALTER TABLE url DROP COLUMN domain;
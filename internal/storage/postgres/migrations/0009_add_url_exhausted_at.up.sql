@@ -0,0 +1,4 @@
+-- exhausted_at is when the last click of the url was used, used up urls
+-- are purged after it like expired ones after expires_at.
+ALTER TABLE url ADD COLUMN exhausted_at BIGINT;
+UPDATE url SET exhausted_at = EXTRACT(EPOCH FROM now())::BIGINT WHERE max_clicks > 0 AND used_clicks >= max_clicks;
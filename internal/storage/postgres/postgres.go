@@ -0,0 +1,833 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"io/fs"
+	"strings"
+	"time"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrator"
+)
+
+// uniqueViolation is the postgres error code of unique constraint violation.
+const uniqueViolation = "23505"
+
+// deadURLs selects urls that expired or ran out of clicks before the first
+// parameter. Urls used up before exhausted_at was recorded are dead too.
+const deadURLs = "expires_at IS NOT NULL AND expires_at <= $1 OR " +
+	"max_clicks > 0 AND used_clicks >= max_clicks AND (exhausted_at IS NULL OR exhausted_at <= $1)"
+
+// urlColumns are columns of url read by scanURL.
+const urlColumns = "id, domain, alias, url, user_id, created_at, clicks, expires_at, redirect_type, password_hash, max_clicks, used_clicks, title, preview"
+
+type Storage struct {
+	db *sql.DB
+}
+
+// apiKeyColumns are columns of api_key read by scanAPIKey.
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, created_at"
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// New connects to the database and applies pending migrations.
+func New(dsn string) (*Storage, error) {
+	const op = "storage.postgres.New"
+
+	s, err := Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := s.Migrator()
+	if err == nil {
+		_, err = m.Up()
+	}
+	if err != nil {
+		_ = s.Close()
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Open connects to the database without touching its schema.
+func Open(dsn string) (*Storage, error) {
+	const op = "storage.postgres.Open"
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Migrator returns migrator of the database schema.
+func (s *Storage) Migrator() (*migrator.Migrator, error) {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrator.New(s.db, migrator.Postgres, fsys)
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.postgres.Ping"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// NextAliasID returns the next value of the sequence ID-based aliases are built from.
+func (s *Storage) NextAliasID(ctx context.Context) (int64, error) {
+	const op = "storage.postgres.NextAliasID"
+
+	var id int64
+	if err := s.db.QueryRowContext(ctx, "SELECT nextval('alias_sequence')").Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) SaveURL(
+	ctx context.Context,
+	urlToSave string,
+	alias string,
+	userID int64,
+	opts storage.URLOptions,
+) (int64, error) {
+	const op = "storage.postgres.SaveURL"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO url(url, domain, alias, user_id, expires_at, redirect_type, password_hash, max_clicks, title, preview)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		urlToSave, opts.Domain, alias, userID, unixOrNil(opts.ExpiresAt), opts.RedirectType, opts.PasswordHash, opts.MaxClicks,
+		opts.Title, opts.Preview,
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveURLs saves urls in one transaction. Urls with taken aliases are
+// skipped, any other error rolls back the whole batch.
+func (s *Storage) SaveURLs(ctx context.Context, userID int64, urls []storage.URLToSave) ([]storage.SaveResult, error) {
+	const op = "storage.postgres.SaveURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// failed insert would abort the transaction, so conflicts are skipped instead
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url(url, domain, alias, user_id, expires_at, redirect_type, password_hash, max_clicks, title, preview)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (domain, alias) DO NOTHING
+	RETURNING id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	results := make([]storage.SaveResult, len(urls))
+	for i, u := range urls {
+		err = stmt.QueryRowContext(ctx,
+			u.URL, u.Opts.Domain, u.Alias, userID, unixOrNil(u.Opts.ExpiresAt), u.Opts.RedirectType, u.Opts.PasswordHash, u.Opts.MaxClicks,
+			u.Opts.Title, u.Opts.Preview,
+		).Scan(&results[i].ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			results[i].Err = fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return results, nil
+}
+
+// GetURL returns the url with given domain and alias unless it's expired.
+func (s *Storage) GetURL(ctx context.Context, domain, alias string) (storage.URL, error) {
+	u, err := s.GetURLInfo(ctx, domain, alias)
+	if err != nil {
+		return storage.URL{}, err
+	}
+
+	if u.ExpiresAt != nil && !u.ExpiresAt.After(time.Now()) {
+		return storage.URL{}, storage.ErrURLExpired
+	}
+
+	if u.MaxClicks > 0 && u.UsedClicks >= u.MaxClicks {
+		return storage.URL{}, storage.ErrURLExhausted
+	}
+
+	return u, nil
+}
+
+// ConsumeClick uses one of clicks the url with given domain and alias is
+// limited to. It returns storage.ErrURLExhausted if none is left. Urls
+// without limit are left untouched.
+func (s *Storage) ConsumeClick(ctx context.Context, domain, alias string) error {
+	const op = "storage.postgres.ConsumeClick"
+
+	// the check and the increment are one statement, so concurrent
+	// redirects can't use more clicks than the url has
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE url SET used_clicks = used_clicks + 1,
+	    exhausted_at = CASE WHEN used_clicks + 1 >= max_clicks THEN $3::BIGINT END
+	WHERE domain = $1 AND alias = $2 AND used_clicks < max_clicks`,
+		domain, alias, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected > 0 {
+		return nil
+	}
+
+	u, err := s.GetURLInfo(ctx, domain, alias)
+	if err != nil {
+		return err
+	}
+
+	if u.MaxClicks == 0 {
+		return nil
+	}
+
+	return storage.ErrURLExhausted
+}
+
+// UpdateURL applies non-nil fields of upd to the url with given domain and alias.
+func (s *Storage) UpdateURL(ctx context.Context, domain, alias string, upd storage.URLUpdate) error {
+	const op = "storage.postgres.UpdateURL"
+
+	var (
+		sets []string
+		args []any
+	)
+
+	if upd.URL != nil {
+		args = append(args, *upd.URL)
+		sets = append(sets, fmt.Sprintf("url = $%d", len(args)))
+	}
+
+	switch {
+	case upd.NoExpiry:
+		sets = append(sets, "expires_at = NULL")
+	case upd.ExpiresAt != nil:
+		args = append(args, upd.ExpiresAt.Unix())
+		sets = append(sets, fmt.Sprintf("expires_at = $%d", len(args)))
+	}
+
+	if upd.RedirectType != nil {
+		args = append(args, *upd.RedirectType)
+		sets = append(sets, fmt.Sprintf("redirect_type = $%d", len(args)))
+	}
+
+	if len(sets) == 0 {
+		// nothing to change, but caller still expects not found error
+		_, err := s.GetURLOwner(ctx, domain, alias)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	args = append(args, domain, alias)
+	query := fmt.Sprintf("UPDATE url SET %s WHERE domain = $%d AND alias = $%d", strings.Join(sets, ", "), len(args)-1, len(args))
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	return nil
+}
+
+// GetURLOwner returns id of the user who created the url with given
+// domain and alias.
+func (s *Storage) GetURLOwner(ctx context.Context, domain, alias string) (int64, error) {
+	const op = "storage.postgres.GetURLOwner"
+
+	var userID int64
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM url WHERE domain = $1 AND alias = $2", domain, alias).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrURLNotFound
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+// GetURLInfo returns the url with given domain and alias, even if it's expired.
+func (s *Storage) GetURLInfo(ctx context.Context, domain, alias string) (storage.URL, error) {
+	const op = "storage.postgres.GetURLInfo"
+
+	u, err := scanURL(s.db.QueryRowContext(ctx,
+		"SELECT "+urlColumns+" FROM url WHERE domain = $1 AND alias = $2", domain, alias))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.URL{}, storage.ErrURLNotFound
+		}
+
+		return storage.URL{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return u, nil
+}
+
+// RenameURL changes alias of the url within its domain. Clicks stay with the url.
+func (s *Storage) RenameURL(ctx context.Context, domain, alias string, newAlias string) error {
+	const op = "storage.postgres.RenameURL"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE url SET alias = $1 WHERE domain = $2 AND alias = $3", newAlias, domain, alias)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteURL(ctx context.Context, domain, alias string) error {
+	const op = "storage.postgres.DeleteURL"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM url_click WHERE url_id IN (SELECT id FROM url WHERE domain = $1 AND alias = $2)", domain, alias)
+	if err != nil {
+		return fmt.Errorf("%s: delete clicks: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM url WHERE domain = $1 AND alias = $2", domain, alias)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListURLs returns a page of urls created by the user with given id.
+// Aliases are compared bytewise to match sqlite ordering.
+func (s *Storage) ListURLs(ctx context.Context, userID int64, params storage.ListURLsParams) ([]storage.URL, error) {
+	const op = "storage.postgres.ListURLs"
+
+	cmp, order := ">", "ASC"
+	if params.Desc {
+		cmp, order = "<", "DESC"
+	}
+
+	query := "SELECT " + urlColumns + " FROM url WHERE user_id = $1"
+	args := []any{userID}
+
+	key := "created_at"
+	if params.SortBy == storage.SortByAlias {
+		key = `alias COLLATE "C"`
+	}
+
+	switch {
+	case params.After == "":
+	case params.SortBy == storage.SortByAlias:
+		args = append(args, params.After, params.AfterID)
+		query += fmt.Sprintf(` AND (alias COLLATE "C", id) %s ($%d, $%d)`, cmp, len(args)-1, len(args))
+	default:
+		createdAt, err := time.Parse(time.RFC3339Nano, params.After)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid cursor: %w", op, err)
+		}
+
+		args = append(args, createdAt, params.AfterID)
+		query += fmt.Sprintf(" AND (created_at, id) %s ($%d, $%d)", cmp, len(args)-1, len(args))
+	}
+
+	args = append(args, params.Limit)
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT $%d", key, order, order, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var urls []storage.URL
+	for rows.Next() {
+		u, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		urls = append(urls, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return urls, nil
+}
+
+// ExportURLs returns a page of urls ordered by id.
+func (s *Storage) ExportURLs(ctx context.Context, filter storage.ExportFilter) ([]storage.URL, error) {
+	const op = "storage.postgres.ExportURLs"
+
+	query := "SELECT " + urlColumns + " FROM url WHERE id > $1"
+	args := []any{filter.AfterID}
+
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		query += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var urls []storage.URL
+	for rows.Next() {
+		u, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		urls = append(urls, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return urls, nil
+}
+
+// ImportURLs saves urls in one transaction keeping their owners, creation
+// times and click counters. Ids of imported urls are assigned anew.
+func (s *Storage) ImportURLs(ctx context.Context, urls []storage.URL, onConflict storage.ConflictPolicy) (storage.ImportStats, error) {
+	const op = "storage.postgres.ImportURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// failed insert would abort the transaction, so conflicts are detected instead
+	insertStmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url(url, alias, user_id, created_at, clicks, expires_at, redirect_type, password_hash,
+	    max_clicks, used_clicks, title, preview, domain, exhausted_at)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	ON CONFLICT (domain, alias) DO NOTHING`)
+	if err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	updateStmt, err := tx.PrepareContext(ctx, `
+	UPDATE url SET url = $1, user_id = $3, created_at = $4, clicks = $5, expires_at = $6, redirect_type = $7,
+	    password_hash = $8, max_clicks = $9, used_clicks = $10, title = $11, preview = $12, exhausted_at = $14
+	WHERE domain = $13 AND alias = $2`)
+	if err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	// used up urls are kept for retention from the import like on this server
+	now := time.Now()
+
+	var stats storage.ImportStats
+	for _, u := range urls {
+		args := []any{
+			u.URL, u.Alias, u.UserID, u.CreatedAt.UTC(), u.Clicks, unixOrNil(u.ExpiresAt), u.RedirectType, u.PasswordHash,
+			u.MaxClicks, u.UsedClicks, u.Title, u.Preview, u.Domain, exhaustedAtOrNil(u, now),
+		}
+
+		res, err := insertStmt.ExecContext(ctx, args...)
+		if err != nil {
+			return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if inserted > 0 {
+			stats.Created++
+			continue
+		}
+
+		switch onConflict {
+		case storage.ConflictSkip:
+			stats.Skipped++
+		case storage.ConflictOverwrite:
+			if _, err = updateStmt.ExecContext(ctx, args...); err != nil {
+				return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+			}
+			stats.Updated++
+		default:
+			return storage.ImportStats{}, fmt.Errorf("%s: alias %q: %w", op, u.Alias, storage.ErrURLExists)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// DeleteExpiredURLs deletes urls that expired before given moment or have
+// no clicks left together with their clicks and returns the number of
+// deleted urls.
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.postgres.DeleteExpiredURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+	DELETE FROM url_click WHERE url_id IN (
+	    SELECT id FROM url WHERE `+deadURLs+`)`,
+		before.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete clicks: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM url WHERE "+deadURLs, before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return affected, nil
+}
+
+// SaveClicks stores click events and increments click counters of their urls.
+// Clicks on urls that no longer exist are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.postgres.SaveClicks"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	insertStmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url_click(url_id, clicked_at, referrer, user_agent, ip_hash)
+	SELECT id, $1::BIGINT, $2::TEXT, $3::TEXT, $4::TEXT FROM url WHERE domain = $5 AND alias = $6`)
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	counterStmt, err := tx.PrepareContext(ctx, "UPDATE url SET clicks = clicks + 1 WHERE domain = $1 AND alias = $2")
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	for _, c := range clicks {
+		_, err = insertStmt.ExecContext(ctx, c.ClickedAt.Unix(), c.Referrer, c.UserAgent, c.IPHash, c.Domain, c.Alias)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = counterStmt.ExecContext(ctx, c.Domain, c.Alias)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetClickStats returns total clicks of the url with given domain and
+// alias and click counts in [from, to) grouped into buckets of given size.
+// Buckets without clicks are omitted.
+func (s *Storage) GetClickStats(
+	ctx context.Context,
+	domain, alias string,
+	from, to time.Time,
+	bucket time.Duration,
+) (storage.ClickStats, error) {
+	const op = "storage.postgres.GetClickStats"
+
+	var stats storage.ClickStats
+
+	var urlID int64
+	err := s.db.QueryRowContext(ctx, "SELECT id, clicks FROM url WHERE domain = $1 AND alias = $2", domain, alias).Scan(&urlID, &stats.Total)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return stats, storage.ErrURLNotFound
+		}
+
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+
+	size := int64(bucket.Seconds())
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT clicked_at / $1 * $1 AS bucket, COUNT(*) FROM url_click
+	WHERE url_id = $2 AND clicked_at >= $3 AND clicked_at < $4
+	GROUP BY bucket ORDER BY bucket`,
+		size, urlID, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var (
+			start int64
+			b     storage.ClickBucket
+		)
+		if err = rows.Scan(&start, &b.Count); err != nil {
+			return stats, fmt.Errorf("%s: %w", op, err)
+		}
+		b.Start = time.Unix(start, 0).UTC()
+		stats.Buckets = append(stats.Buckets, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// SaveAPIKey saves key of its user and returns its id.
+func (s *Storage) SaveAPIKey(ctx context.Context, key storage.APIKey) (int64, error) {
+	const op = "storage.postgres.SaveAPIKey"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+	INSERT INTO api_key(user_id, name, prefix, key_hash, scopes, created_at) VALUES($1, $2, $3, $4, $5, $6)
+	RETURNING id`,
+		key.UserID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, " "), key.CreatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetAPIKey returns key with given hash.
+func (s *Storage) GetAPIKey(ctx context.Context, hash string) (storage.APIKey, error) {
+	const op = "storage.postgres.GetAPIKey"
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_key WHERE key_hash = $1", hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns keys of user in order they were created.
+func (s *Storage) ListAPIKeys(ctx context.Context, userID int64) ([]storage.APIKey, error) {
+	const op = "storage.postgres.ListAPIKeys"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_key WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := make([]storage.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey revokes key of user. Keys of other users are not found.
+func (s *Storage) DeleteAPIKey(ctx context.Context, userID, id int64) error {
+	const op = "storage.postgres.DeleteAPIKey"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM api_key WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// scanURL reads url selected as urlColumns.
+func scanURL(row interface{ Scan(dest ...any) error }) (storage.URL, error) {
+	var (
+		u         storage.URL
+		expiresAt sql.NullInt64
+	)
+	err := row.Scan(&u.ID, &u.Domain, &u.Alias, &u.URL, &u.UserID, &u.CreatedAt, &u.Clicks, &expiresAt, &u.RedirectType, &u.PasswordHash,
+		&u.MaxClicks, &u.UsedClicks, &u.Title, &u.Preview)
+	if err != nil {
+		return storage.URL{}, err
+	}
+	u.CreatedAt = u.CreatedAt.UTC()
+	u.ExpiresAt = timeOrNil(expiresAt)
+
+	return u, nil
+}
+
+// scanAPIKey reads key selected as apiKeyColumns.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (storage.APIKey, error) {
+	var (
+		key    storage.APIKey
+		scopes string
+	)
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt)
+	if err != nil {
+		return storage.APIKey{}, err
+	}
+	key.Scopes = strings.Fields(scopes)
+
+	return key, nil
+}
+
+// exhaustedAtOrNil returns now for url that has no clicks left.
+func exhaustedAtOrNil(u storage.URL, now time.Time) any {
+	if u.MaxClicks == 0 || u.UsedClicks < u.MaxClicks {
+		return nil
+	}
+
+	return now.Unix()
+}
+
+func unixOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return t.Unix()
+}
+
+func timeOrNil(unix sql.NullInt64) *time.Time {
+	if !unix.Valid {
+		return nil
+	}
+
+	t := time.Unix(unix.Int64, 0).UTC()
+
+	return &t
+}
@@ -0,0 +1,7 @@
+-- Databases created before migrations were introduced already have this
+-- table, so it's created only if missing.
+CREATE TABLE IF NOT EXISTS url(
+    id INTEGER PRIMARY KEY,
+    alias TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL);
+CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
@@ -0,0 +1,10 @@
+DROP TABLE url_click;
+
+CREATE TABLE url_old(
+    id INTEGER PRIMARY KEY,
+    alias TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL);
+INSERT INTO url_old(id, alias, url) SELECT id, alias, url FROM url;
+DROP TABLE url;
+ALTER TABLE url_old RENAME TO url;
+CREATE INDEX idx_alias ON url(alias);
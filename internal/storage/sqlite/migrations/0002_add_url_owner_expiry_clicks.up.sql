@@ -0,0 +1,25 @@
+-- sqlite can't add a column with non-constant default, so url is rebuilt.
+-- created_at of existing urls is set to the migration time.
+CREATE TABLE url_new(
+    id INTEGER PRIMARY KEY,
+    alias TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    clicks INTEGER NOT NULL DEFAULT 0,
+    expires_at INTEGER);
+INSERT INTO url_new(id, alias, url) SELECT id, alias, url FROM url;
+DROP TABLE url;
+ALTER TABLE url_new RENAME TO url;
+CREATE INDEX idx_alias ON url(alias);
+CREATE INDEX idx_user_id ON url(user_id);
+CREATE INDEX idx_expires_at ON url(expires_at);
+
+CREATE TABLE url_click(
+    id INTEGER PRIMARY KEY,
+    url_id INTEGER NOT NULL,
+    clicked_at INTEGER NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_hash TEXT NOT NULL DEFAULT '');
+CREATE INDEX idx_url_click_url_id_clicked_at ON url_click(url_id, clicked_at);
@@ -0,0 +1 @@
+DROP TABLE alias_sequence;
@@ -0,0 +1,3 @@
+-- Single row counter ID-based aliases are built from.
+CREATE TABLE alias_sequence(value INTEGER NOT NULL);
+INSERT INTO alias_sequence(value) VALUES(0);
@@ -0,0 +1,2 @@
+-- 0 means the redirect type configured on the server.
+ALTER TABLE url ADD COLUMN redirect_type INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- empty for links that are not protected by password.
+ALTER TABLE url ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
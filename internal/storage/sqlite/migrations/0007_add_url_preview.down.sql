@@ -0,0 +1,2 @@
+ALTER TABLE url DROP COLUMN preview;
+ALTER TABLE url DROP COLUMN title;
@@ -0,0 +1,26 @@
+-- sqlite can't change the unique constraint of alias, so url is rebuilt.
+-- Aliases are unique per domain, urls of the primary domain have empty one.
+CREATE TABLE url_new(
+    id INTEGER PRIMARY KEY,
+    domain TEXT NOT NULL DEFAULT '',
+    alias TEXT NOT NULL,
+    url TEXT NOT NULL,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    clicks INTEGER NOT NULL DEFAULT 0,
+    expires_at INTEGER,
+    redirect_type INTEGER NOT NULL DEFAULT 0,
+    password_hash TEXT NOT NULL DEFAULT '',
+    max_clicks INTEGER NOT NULL DEFAULT 0,
+    used_clicks INTEGER NOT NULL DEFAULT 0,
+    title TEXT NOT NULL DEFAULT '',
+    preview BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE(domain, alias));
+INSERT INTO url_new(id, alias, url, user_id, created_at, clicks, expires_at, redirect_type, password_hash,
+    max_clicks, used_clicks, title, preview)
+SELECT id, alias, url, user_id, created_at, clicks, expires_at, redirect_type, password_hash,
+    max_clicks, used_clicks, title, preview FROM url;
+DROP TABLE url;
+ALTER TABLE url_new RENAME TO url;
+CREATE INDEX idx_user_id ON url(user_id);
+CREATE INDEX idx_expires_at ON url(expires_at);
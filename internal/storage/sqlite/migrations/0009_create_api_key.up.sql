@@ -0,0 +1,11 @@
+-- Keys of machine clients. Only sha256 of a key is stored, prefix is its
+-- start shown to tell keys apart. Scopes are separated by spaces.
+CREATE TABLE api_key(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP);
+CREATE INDEX idx_api_key_user_id ON api_key(user_id);
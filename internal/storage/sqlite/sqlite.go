@@ -1,54 +1,230 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"embed"
 	"errors"
 	"fmt"
 	"github.com/mattn/go-sqlite3"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
 	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrator"
 )
 
 type Storage struct {
 	db *sql.DB
 }
 
+// deadURLs selects urls that expired or ran out of clicks before the first
+// parameter. Urls used up before exhausted_at was recorded are dead too.
+const deadURLs = "expires_at IS NOT NULL AND expires_at <= ?1 OR " +
+	"max_clicks > 0 AND used_clicks >= max_clicks AND (exhausted_at IS NULL OR exhausted_at <= ?1)"
+
+// urlColumns are columns of url read by scanURL.
+const urlColumns = "id, domain, alias, url, user_id, created_at, clicks, expires_at, redirect_type, password_hash, max_clicks, used_clicks, title, preview"
+
+// apiKeyColumns are columns of api_key read by scanAPIKey.
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, created_at"
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// New opens the database at storagePath and applies pending migrations.
 func New(storagePath string) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
+	s, err := Open(storagePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := s.Migrator()
+	if err == nil {
+		_, err = m.Up()
+	}
+	if err != nil {
+		_ = s.Close()
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Open opens the database at storagePath without touching its schema.
+func Open(storagePath string) (*Storage, error) {
+	const op = "storage.sqlite.Open"
+
 	db, err := sql.Open("sqlite3", storagePath)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	stmt, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS url(
+	return &Storage{db: db}, nil
+}
+
+// Migrator returns migrator of the database schema. Databases of
+// versions without migrations are upgraded first, see upgradeLegacy.
+func (s *Storage) Migrator() (*migrator.Migrator, error) {
+	const op = "storage.sqlite.Migrator"
+
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrator.New(s.db, migrator.SQLite, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.upgradeLegacy(m); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return m, nil
+}
+
+// legacyVersion is the migration whose schema matches databases created
+// by versions that changed the schema without migrations.
+const legacyVersion = 2
+
+// legacyColumns are url columns those versions added.
+var legacyColumns = []string{"user_id", "created_at", "clicks", "expires_at"}
+
+// upgradeLegacy brings databases created without migrations but after
+// url got owners to the schema of legacyVersion and marks it applied.
+// Migration 0002 would drop their new columns and fail on url_click, so
+// url is rebuilt keeping the columns it has. Databases with the original
+// url table are left to the migrations.
+func (s *Storage) upgradeLegacy(m *migrator.Migrator) error {
+	version, err := m.Version()
+	if err != nil || version >= legacyVersion {
+		return err
+	}
+
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info('url')")
+	if err != nil {
+		return fmt.Errorf("read url columns: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return fmt.Errorf("read url columns: %w", err)
+		}
+		columns = append(columns, name)
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("read url columns: %w", err)
+	}
+
+	if !slices.Contains(columns, "user_id") {
+		return nil
+	}
+
+	copied := "id, alias, url"
+	for _, c := range legacyColumns {
+		if slices.Contains(columns, c) {
+			copied += ", " + c
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// created_at of urls saved before it was recorded is set to the upgrade time
+	_, err = tx.Exec(`
+	CREATE TABLE url_new(
 	    id INTEGER PRIMARY KEY,
 	    alias TEXT NOT NULL UNIQUE,
-	    url TEXT NOT NULL);
-	CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
+	    url TEXT NOT NULL,
+	    user_id INTEGER NOT NULL DEFAULT 0,
+	    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	    clicks INTEGER NOT NULL DEFAULT 0,
+	    expires_at INTEGER);
+	INSERT INTO url_new(` + copied + `) SELECT ` + copied + ` FROM url;
+	DROP TABLE url;
+	ALTER TABLE url_new RENAME TO url;
+	CREATE INDEX idx_alias ON url(alias);
+	CREATE INDEX idx_user_id ON url(user_id);
+	CREATE INDEX idx_expires_at ON url(expires_at);
+	CREATE TABLE IF NOT EXISTS url_click(
+	    id INTEGER PRIMARY KEY,
+	    url_id INTEGER NOT NULL,
+	    clicked_at INTEGER NOT NULL,
+	    referrer TEXT NOT NULL DEFAULT '',
+	    user_agent TEXT NOT NULL DEFAULT '',
+	    ip_hash TEXT NOT NULL DEFAULT '');
+	CREATE INDEX IF NOT EXISTS idx_url_click_url_id_clicked_at ON url_click(url_id, clicked_at);
 	`)
-
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("upgrade legacy schema: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
 	}
 
-	_, err = stmt.Exec()
+	return m.Baseline(legacyVersion)
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.sqlite.Ping"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// NextAliasID returns the next value of the sequence ID-based aliases are built from.
+func (s *Storage) NextAliasID(ctx context.Context) (int64, error) {
+	const op = "storage.sqlite.NextAliasID"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, "UPDATE alias_sequence SET value = value + 1 RETURNING value").Scan(&id)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	return id, nil
 }
 
-func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
+func (s *Storage) SaveURL(
+	ctx context.Context,
+	urlToSave string,
+	alias string,
+	userID int64,
+	opts storage.URLOptions,
+) (int64, error) {
 	const op = "storage.sqlite.SaveURL"
 
-	stmt, err := s.db.Prepare("INSERT INTO url(url, alias) VALUES(?, ?)")
+	stmt, err := s.db.PrepareContext(ctx, `
+	INSERT INTO url(url, domain, alias, user_id, expires_at, redirect_type, password_hash, max_clicks, title, preview)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	res, err := stmt.Exec(urlToSave, alias)
+	res, err := stmt.ExecContext(ctx,
+		urlToSave, opts.Domain, alias, userID, unixOrNil(opts.ExpiresAt), opts.RedirectType, opts.PasswordHash, opts.MaxClicks,
+		opts.Title, opts.Preview)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
@@ -65,43 +241,721 @@ func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
 	return id, nil
 }
 
-func (s *Storage) GetURL(alias string) (string, error) {
-	const op = "storage.sqlite.GetURL"
+// SaveURLs saves urls in one transaction. Urls with taken aliases are
+// skipped, any other error rolls back the whole batch.
+func (s *Storage) SaveURLs(ctx context.Context, userID int64, urls []storage.URLToSave) ([]storage.SaveResult, error) {
+	const op = "storage.sqlite.SaveURLs"
 
-	stmt, err := s.db.Prepare("SELECT url FROM url WHERE alias = ?")
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return "", fmt.Errorf("%s: prepare statement: %w", op, err)
+		return nil, fmt.Errorf("%s: begin transaction: %w", op, err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	var resURL string
-	err = stmt.QueryRow(alias).Scan(&resURL)
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url(url, domain, alias, user_id, expires_at, redirect_type, password_hash, max_clicks, title, preview)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	results := make([]storage.SaveResult, len(urls))
+	for i, u := range urls {
+		// failed statement is undone alone, the transaction goes on
+		res, err := stmt.ExecContext(ctx,
+			u.URL, u.Opts.Domain, u.Alias, userID, unixOrNil(u.Opts.ExpiresAt), u.Opts.RedirectType, u.Opts.PasswordHash, u.Opts.MaxClicks,
+			u.Opts.Title, u.Opts.Preview)
+		if err != nil {
+			var sqliteErr sqlite3.Error
+			if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+				results[i].Err = fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		results[i].ID, err = res.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to get last insert id: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return results, nil
+}
+
+// GetURL returns the url with given domain and alias unless it's expired.
+func (s *Storage) GetURL(ctx context.Context, domain, alias string) (storage.URL, error) {
+	u, err := s.GetURLInfo(ctx, domain, alias)
+	if err != nil {
+		return storage.URL{}, err
+	}
+
+	if u.ExpiresAt != nil && !u.ExpiresAt.After(time.Now()) {
+		return storage.URL{}, storage.ErrURLExpired
+	}
+
+	if u.MaxClicks > 0 && u.UsedClicks >= u.MaxClicks {
+		return storage.URL{}, storage.ErrURLExhausted
+	}
+
+	return u, nil
+}
+
+// ConsumeClick uses one of clicks the url with given domain and alias is
+// limited to. It returns storage.ErrURLExhausted if none is left. Urls
+// without limit are left untouched.
+func (s *Storage) ConsumeClick(ctx context.Context, domain, alias string) error {
+	const op = "storage.sqlite.ConsumeClick"
+
+	// the check and the increment are one statement, so concurrent
+	// redirects can't use more clicks than the url has
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE url SET used_clicks = used_clicks + 1,
+	    exhausted_at = CASE WHEN used_clicks + 1 >= max_clicks THEN ? END
+	WHERE domain = ? AND alias = ? AND used_clicks < max_clicks`,
+		time.Now().Unix(), domain, alias,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected > 0 {
+		return nil
+	}
+
+	u, err := s.GetURLInfo(ctx, domain, alias)
+	if err != nil {
+		return err
+	}
+
+	if u.MaxClicks == 0 {
+		return nil
+	}
+
+	return storage.ErrURLExhausted
+}
+
+// UpdateURL applies non-nil fields of upd to the url with given domain and alias.
+func (s *Storage) UpdateURL(ctx context.Context, domain, alias string, upd storage.URLUpdate) error {
+	const op = "storage.sqlite.UpdateURL"
+
+	var (
+		sets []string
+		args []any
+	)
+
+	if upd.URL != nil {
+		sets = append(sets, "url = ?")
+		args = append(args, *upd.URL)
+	}
+
+	switch {
+	case upd.NoExpiry:
+		sets = append(sets, "expires_at = NULL")
+	case upd.ExpiresAt != nil:
+		sets = append(sets, "expires_at = ?")
+		args = append(args, upd.ExpiresAt.Unix())
+	}
+
+	if upd.RedirectType != nil {
+		sets = append(sets, "redirect_type = ?")
+		args = append(args, *upd.RedirectType)
+	}
+
+	if len(sets) == 0 {
+		// nothing to change, but caller still expects not found error
+		_, err := s.GetURLOwner(ctx, domain, alias)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, "UPDATE url SET "+strings.Join(sets, ", ")+" WHERE domain = ? AND alias = ?")
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	res, err := stmt.ExecContext(ctx, append(args, domain, alias)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	return nil
+}
+
+// GetURLOwner returns id of the user who created the url with given
+// domain and alias.
+func (s *Storage) GetURLOwner(ctx context.Context, domain, alias string) (int64, error) {
+	const op = "storage.sqlite.GetURLOwner"
+
+	stmt, err := s.db.PrepareContext(ctx, "SELECT user_id FROM url WHERE domain = ? AND alias = ?")
+	if err != nil {
+		return 0, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	var userID int64
+	err = stmt.QueryRowContext(ctx, domain, alias).Scan(&userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", storage.ErrURLNotFound
+			return 0, storage.ErrURLNotFound
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+// GetURLInfo returns the url with given domain and alias, even if it's expired.
+func (s *Storage) GetURLInfo(ctx context.Context, domain, alias string) (storage.URL, error) {
+	const op = "storage.sqlite.GetURLInfo"
+
+	stmt, err := s.db.PrepareContext(ctx, "SELECT "+urlColumns+" FROM url WHERE domain = ? AND alias = ?")
+	if err != nil {
+		return storage.URL{}, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	u, err := scanURL(stmt.QueryRowContext(ctx, domain, alias))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.URL{}, storage.ErrURLNotFound
+		}
+
+		return storage.URL{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return u, nil
+}
+
+// RenameURL changes alias of the url within its domain. Clicks stay with the url.
+func (s *Storage) RenameURL(ctx context.Context, domain, alias string, newAlias string) error {
+	const op = "storage.sqlite.RenameURL"
+
+	stmt, err := s.db.PrepareContext(ctx, "UPDATE url SET alias = ? WHERE domain = ? AND alias = ?")
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	res, err := stmt.ExecContext(ctx, newAlias, domain, alias)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrURLExists)
 		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
 
-		return "", fmt.Errorf("%s: %w", op, err)
+	if affected == 0 {
+		return storage.ErrURLNotFound
 	}
 
-	return resURL, nil
+	return nil
 }
 
-func (s *Storage) DeleteURL(alias string) error {
+func (s *Storage) DeleteURL(ctx context.Context, domain, alias string) error {
 	const op = "storage.sqlite.DeleteURL"
 
-	stmt, err := s.db.Prepare("DELETE FROM url WHERE alias = ?")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM url_click WHERE url_id IN (SELECT id FROM url WHERE domain = ? AND alias = ?)", domain, alias)
+	if err != nil {
+		return fmt.Errorf("%s: delete clicks: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM url WHERE domain = ? AND alias = ?", domain, alias)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = stmt.Exec(alias)
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListURLs returns a page of urls created by the user with given id.
+// Pagination is keyset based, see storage.ListURLsParams. Creation times
+// are compared as julian days, saved and imported urls store them in
+// different text formats.
+func (s *Storage) ListURLs(ctx context.Context, userID int64, params storage.ListURLsParams) ([]storage.URL, error) {
+	const op = "storage.sqlite.ListURLs"
+
+	cmp, order := ">", "ASC"
+	if params.Desc {
+		cmp, order = "<", "DESC"
+	}
+
+	query := "SELECT " + urlColumns + " FROM url WHERE user_id = ?"
+	args := []any{userID}
+
+	key := "julianday(created_at)"
+	if params.SortBy == storage.SortByAlias {
+		key = "alias"
+	}
+
+	switch {
+	case params.After == "":
+	case params.SortBy == storage.SortByAlias:
+		query += fmt.Sprintf(" AND (alias, id) %s (?, ?)", cmp)
+		args = append(args, params.After, params.AfterID)
+	default:
+		createdAt, err := time.Parse(time.RFC3339Nano, params.After)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid cursor: %w", op, err)
+		}
+
+		query += fmt.Sprintf(" AND (julianday(created_at), id) %s (julianday(?), ?)", cmp)
+		args = append(args, createdAt.UTC(), params.AfterID)
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ?", key, order, order)
+	args = append(args, params.Limit)
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var urls []storage.URL
+	for rows.Next() {
+		u, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		urls = append(urls, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return urls, nil
+}
+
+// ExportURLs returns a page of urls ordered by id.
+func (s *Storage) ExportURLs(ctx context.Context, filter storage.ExportFilter) ([]storage.URL, error) {
+	const op = "storage.sqlite.ExportURLs"
+
+	query := "SELECT " + urlColumns + " FROM url WHERE id > ?"
+	args := []any{filter.AfterID}
+
+	if filter.UserID != nil {
+		query += " AND user_id = ?"
+		args = append(args, *filter.UserID)
+	}
+
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, filter.Limit)
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var urls []storage.URL
+	for rows.Next() {
+		u, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		urls = append(urls, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return urls, nil
+}
+
+// ImportURLs saves urls in one transaction keeping their owners, creation
+// times and click counters. Ids of imported urls are assigned anew.
+func (s *Storage) ImportURLs(ctx context.Context, urls []storage.URL, onConflict storage.ConflictPolicy) (storage.ImportStats, error) {
+	const op = "storage.sqlite.ImportURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	insertStmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url(url, alias, user_id, created_at, clicks, expires_at, redirect_type, password_hash,
+	    max_clicks, used_clicks, title, preview, domain, exhausted_at)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	updateStmt, err := tx.PrepareContext(ctx, `
+	UPDATE url SET url = ?1, user_id = ?3, created_at = ?4, clicks = ?5, expires_at = ?6, redirect_type = ?7,
+	    password_hash = ?8, max_clicks = ?9, used_clicks = ?10, title = ?11, preview = ?12, exhausted_at = ?14
+	WHERE domain = ?13 AND alias = ?2`)
+	if err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	// used up urls are kept for retention from the import like on this server
+	now := time.Now()
+
+	var stats storage.ImportStats
+	for _, u := range urls {
+		args := []any{
+			u.URL, u.Alias, u.UserID, u.CreatedAt.UTC(), u.Clicks, unixOrNil(u.ExpiresAt), u.RedirectType, u.PasswordHash,
+			u.MaxClicks, u.UsedClicks, u.Title, u.Preview, u.Domain, exhaustedAtOrNil(u, now),
+		}
+
+		// failed statement is undone alone, the transaction goes on
+		_, err := insertStmt.ExecContext(ctx, args...)
+		if err == nil {
+			stats.Created++
+			continue
+		}
+
+		var sqliteErr sqlite3.Error
+		if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+			return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		switch onConflict {
+		case storage.ConflictSkip:
+			stats.Skipped++
+		case storage.ConflictOverwrite:
+			if _, err = updateStmt.ExecContext(ctx, args...); err != nil {
+				return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+			}
+			stats.Updated++
+		default:
+			return storage.ImportStats{}, fmt.Errorf("%s: alias %q: %w", op, u.Alias, storage.ErrURLExists)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// DeleteExpiredURLs deletes urls that expired before given moment or have
+// no clicks left together with their clicks and returns the number of
+// deleted urls.
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+	DELETE FROM url_click WHERE url_id IN (
+	    SELECT id FROM url WHERE `+deadURLs+`)`,
+		before.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete clicks: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM url WHERE "+deadURLs, before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return affected, nil
+}
+
+// SaveClicks stores click events and increments click counters of their urls.
+// Clicks on urls that no longer exist are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.sqlite.SaveClicks"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	insertStmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url_click(url_id, clicked_at, referrer, user_agent, ip_hash)
+	SELECT id, ?, ?, ?, ? FROM url WHERE domain = ? AND alias = ?`)
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	counterStmt, err := tx.PrepareContext(ctx, "UPDATE url SET clicks = clicks + 1 WHERE domain = ? AND alias = ?")
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+
+	for _, c := range clicks {
+		_, err = insertStmt.ExecContext(ctx, c.ClickedAt.Unix(), c.Referrer, c.UserAgent, c.IPHash, c.Domain, c.Alias)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = counterStmt.ExecContext(ctx, c.Domain, c.Alias)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetClickStats returns total clicks of the url with given domain and
+// alias and click counts in [from, to) grouped into buckets of given size.
+// Buckets without clicks are omitted.
+func (s *Storage) GetClickStats(
+	ctx context.Context,
+	domain, alias string,
+	from, to time.Time,
+	bucket time.Duration,
+) (storage.ClickStats, error) {
+	const op = "storage.sqlite.GetClickStats"
+
+	var stats storage.ClickStats
+
+	var urlID int64
+	err := s.db.QueryRowContext(ctx, "SELECT id, clicks FROM url WHERE domain = ? AND alias = ?", domain, alias).Scan(&urlID, &stats.Total)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return storage.ErrURLNotFound
+			return stats, storage.ErrURLNotFound
+		}
+
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+
+	size := int64(bucket.Seconds())
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT clicked_at / ? * ? AS bucket, COUNT(*) FROM url_click
+	WHERE url_id = ? AND clicked_at >= ? AND clicked_at < ?
+	GROUP BY bucket ORDER BY bucket`,
+		size, size, urlID, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var (
+			start int64
+			b     storage.ClickBucket
+		)
+		if err = rows.Scan(&start, &b.Count); err != nil {
+			return stats, fmt.Errorf("%s: %w", op, err)
+		}
+		b.Start = time.Unix(start, 0).UTC()
+		stats.Buckets = append(stats.Buckets, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// SaveAPIKey saves key of its user and returns its id.
+func (s *Storage) SaveAPIKey(ctx context.Context, key storage.APIKey) (int64, error) {
+	const op = "storage.sqlite.SaveAPIKey"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+	INSERT INTO api_key(user_id, name, prefix, key_hash, scopes, created_at) VALUES(?, ?, ?, ?, ?, ?)
+	RETURNING id`,
+		key.UserID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, " "), key.CreatedAt.UTC(),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetAPIKey returns key with given hash.
+func (s *Storage) GetAPIKey(ctx context.Context, hash string) (storage.APIKey, error) {
+	const op = "storage.sqlite.GetAPIKey"
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_key WHERE key_hash = ?", hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns keys of user in order they were created.
+func (s *Storage) ListAPIKeys(ctx context.Context, userID int64) ([]storage.APIKey, error) {
+	const op = "storage.sqlite.ListAPIKeys"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_key WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := make([]storage.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey revokes key of user. Keys of other users are not found.
+func (s *Storage) DeleteAPIKey(ctx context.Context, userID, id int64) error {
+	const op = "storage.sqlite.DeleteAPIKey"
 
+	res, err := s.db.ExecContext(ctx, "DELETE FROM api_key WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrAPIKeyNotFound
+	}
+
 	return nil
 }
+
+// scanURL reads url selected as urlColumns.
+func scanURL(row interface{ Scan(dest ...any) error }) (storage.URL, error) {
+	var (
+		u         storage.URL
+		expiresAt sql.NullInt64
+	)
+	err := row.Scan(&u.ID, &u.Domain, &u.Alias, &u.URL, &u.UserID, &u.CreatedAt, &u.Clicks, &expiresAt, &u.RedirectType, &u.PasswordHash,
+		&u.MaxClicks, &u.UsedClicks, &u.Title, &u.Preview)
+	if err != nil {
+		return storage.URL{}, err
+	}
+	u.ExpiresAt = timeOrNil(expiresAt)
+
+	return u, nil
+}
+
+// scanAPIKey reads key selected as apiKeyColumns.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (storage.APIKey, error) {
+	var (
+		key    storage.APIKey
+		scopes string
+	)
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt)
+	if err != nil {
+		return storage.APIKey{}, err
+	}
+	key.Scopes = strings.Fields(scopes)
+
+	return key, nil
+}
+
+// exhaustedAtOrNil returns now for url that has no clicks left.
+func exhaustedAtOrNil(u storage.URL, now time.Time) any {
+	if u.MaxClicks == 0 || u.UsedClicks < u.MaxClicks {
+		return nil
+	}
+
+	return now.Unix()
+}
+
+func unixOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return t.Unix()
+}
+
+func timeOrNil(unix sql.NullInt64) *time.Time {
+	if !unix.Valid {
+		return nil
+	}
+
+	t := time.Unix(unix.Int64, 0).UTC()
+
+	return &t
+}
@@ -0,0 +1,221 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/sqlite"
+	"url-shortener/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = s.Close() })
+
+		return s
+	})
+}
+
+func TestNew_UpgradesLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	// schema created by versions without migrations
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+	CREATE TABLE url(
+	    id INTEGER PRIMARY KEY,
+	    alias TEXT NOT NULL UNIQUE,
+	    url TEXT NOT NULL);
+	INSERT INTO url(alias, url) VALUES('legacy', 'https://example.com');
+	`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s, err := sqlite.New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	got, err := s.GetURL(context.Background(), "", "legacy")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", got.URL)
+
+	owner, err := s.GetURLOwner(context.Background(), "", "legacy")
+	require.NoError(t, err)
+	require.Zero(t, owner)
+
+	_, err = s.SaveURL(context.Background(), "https://example.com/new", "new", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	m, err := s.Migrator()
+	require.NoError(t, err)
+
+	version, err := m.Version()
+	require.NoError(t, err)
+	require.Equal(t, m.Latest(), version)
+
+	// urls survive rolling back to the legacy schema and upgrading again
+	rolledBack, err := m.Down(m.Latest() - 1)
+	require.NoError(t, err)
+	require.Equal(t, m.Latest()-1, rolledBack)
+
+	applied, err := m.Up()
+	require.NoError(t, err)
+	require.Equal(t, m.Latest()-1, applied)
+
+	got, err = s.GetURL(context.Background(), "", "new")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/new", got.URL)
+}
+
+func TestNew_UpgradesLegacyDatabaseWithOwners(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema string
+		clicks int64
+	}{
+		{
+			name: "Owner only",
+			schema: `
+			CREATE TABLE url(
+			    id INTEGER PRIMARY KEY,
+			    alias TEXT NOT NULL UNIQUE,
+			    url TEXT NOT NULL,
+			    user_id INTEGER NOT NULL DEFAULT 0);
+			CREATE INDEX idx_alias ON url(alias);
+			INSERT INTO url(alias, url, user_id) VALUES('legacy', 'https://example.com', 7);
+			`,
+		},
+		{
+			name: "Clicks and expiry",
+			schema: `
+			CREATE TABLE url(
+			    id INTEGER PRIMARY KEY,
+			    alias TEXT NOT NULL UNIQUE,
+			    url TEXT NOT NULL,
+			    user_id INTEGER NOT NULL DEFAULT 0,
+			    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			    clicks INTEGER NOT NULL DEFAULT 0,
+			    expires_at INTEGER);
+			CREATE INDEX idx_alias ON url(alias);
+			CREATE INDEX idx_user_id ON url(user_id);
+			CREATE INDEX idx_expires_at ON url(expires_at);
+			CREATE TABLE url_click(
+			    id INTEGER PRIMARY KEY,
+			    url_id INTEGER NOT NULL,
+			    clicked_at INTEGER NOT NULL,
+			    referrer TEXT NOT NULL DEFAULT '',
+			    user_agent TEXT NOT NULL DEFAULT '',
+			    ip_hash TEXT NOT NULL DEFAULT '');
+			CREATE INDEX idx_url_click_url_id_clicked_at ON url_click(url_id, clicked_at);
+			INSERT INTO url(alias, url, user_id, clicks) VALUES('legacy', 'https://example.com', 7, 3);
+			INSERT INTO url_click(url_id, clicked_at) VALUES(1, 1700000000);
+			`,
+			clicks: 3,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "storage.db")
+
+			db, err := sql.Open("sqlite3", path)
+			require.NoError(t, err)
+			_, err = db.Exec(tc.schema)
+			require.NoError(t, err)
+			require.NoError(t, db.Close())
+
+			s, err := sqlite.New(path)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = s.Close() })
+
+			ctx := context.Background()
+
+			owner, err := s.GetURLOwner(ctx, "", "legacy")
+			require.NoError(t, err)
+			require.Equal(t, int64(7), owner)
+
+			urls, err := s.ListURLs(ctx, 7, storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, urls, 1)
+			require.Equal(t, "https://example.com", urls[0].URL)
+			require.Equal(t, tc.clicks, urls[0].Clicks)
+
+			m, err := s.Migrator()
+			require.NoError(t, err)
+
+			version, err := m.Version()
+			require.NoError(t, err)
+			require.Equal(t, m.Latest(), version)
+		})
+	}
+}
+
+func TestConsumeClick_Concurrent(t *testing.T) {
+	const (
+		maxClicks = 10
+		redirects = 200
+	)
+
+	s, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	ctx := context.Background()
+
+	_, err = s.SaveURL(ctx, "https://example.com", "secret", 1, storage.URLOptions{MaxClicks: maxClicks})
+	require.NoError(t, err)
+
+	var (
+		wg        sync.WaitGroup
+		served    atomic.Int64
+		exhausted atomic.Int64
+	)
+	start := make(chan struct{})
+	errs := make(chan error, redirects)
+
+	for i := 0; i < redirects; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			err := s.ConsumeClick(ctx, "", "secret")
+			switch {
+			case err == nil:
+				served.Add(1)
+			case errors.Is(err, storage.ErrURLExhausted):
+				exhausted.Add(1)
+			default:
+				errs <- err
+			}
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(maxClicks), served.Load())
+	require.Equal(t, int64(redirects-maxClicks), exhausted.Load())
+
+	info, err := s.GetURLInfo(ctx, "", "secret")
+	require.NoError(t, err)
+	require.Equal(t, int64(maxClicks), info.UsedClicks)
+}
@@ -1,10 +1,197 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"time"
 )
 
 var (
 	ErrURLNotFound = errors.New("url not found")
 	ErrURLExists   = errors.New("url exists")
+	ErrURLExpired  = errors.New("url expired")
+	// ErrURLExhausted is returned for urls that were opened MaxClicks times.
+	ErrURLExhausted = errors.New("url has no clicks left")
+
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// Storage is implemented by every storage backend. Handlers depend on
+// their own small interfaces, this one is used to pick a backend at startup.
+// Implementations must return the sentinel errors above.
+type Storage interface {
+	SaveURL(ctx context.Context, urlToSave string, alias string, userID int64, opts URLOptions) (int64, error)
+	SaveURLs(ctx context.Context, userID int64, urls []URLToSave) ([]SaveResult, error)
+	GetURL(ctx context.Context, domain, alias string) (URL, error)
+	ConsumeClick(ctx context.Context, domain, alias string) error
+	GetURLOwner(ctx context.Context, domain, alias string) (int64, error)
+	GetURLInfo(ctx context.Context, domain, alias string) (URL, error)
+	UpdateURL(ctx context.Context, domain, alias string, upd URLUpdate) error
+	RenameURL(ctx context.Context, domain, alias string, newAlias string) error
+	DeleteURL(ctx context.Context, domain, alias string) error
+	ListURLs(ctx context.Context, userID int64, params ListURLsParams) ([]URL, error)
+	ExportURLs(ctx context.Context, filter ExportFilter) ([]URL, error)
+	ImportURLs(ctx context.Context, urls []URL, onConflict ConflictPolicy) (ImportStats, error)
+	DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error)
+	SaveClicks(ctx context.Context, clicks []Click) error
+	GetClickStats(ctx context.Context, domain, alias string, from, to time.Time, bucket time.Duration) (ClickStats, error)
+	SaveAPIKey(ctx context.Context, key APIKey) (int64, error)
+	GetAPIKey(ctx context.Context, hash string) (APIKey, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]APIKey, error)
+	DeleteAPIKey(ctx context.Context, userID, id int64) error
+	NextAliasID(ctx context.Context) (int64, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// URL is a stored short link.
+type URL struct {
+	ID int64
+	// Domain is short domain the url is served on, empty for the primary
+	// one. Aliases are unique per domain.
+	Domain    string
+	Alias     string
+	URL       string
+	UserID    int64
+	CreatedAt time.Time
+	Clicks    int64
+	ExpiresAt *time.Time
+	// RedirectType is HTTP status of the redirect, zero means the configured default.
+	RedirectType int
+	// PasswordHash is bcrypt hash of password protecting the url, empty if there's none.
+	PasswordHash string
+	// MaxClicks is how many redirects the url serves, zero means unlimited.
+	// UsedClicks counts them, unlike Clicks it's updated on every redirect.
+	MaxClicks  int64
+	UsedClicks int64
+	// Title describes the url on its preview page.
+	Title string
+	// Preview makes redirects show preview page of the url first.
+	Preview bool
+}
+
+// URLOptions contains optional settings of a url being saved.
+type URLOptions struct {
+	// Domain is short domain the url is served on, empty for the primary one.
+	Domain string
+	// ExpiresAt is the moment url stops resolving. Nil means never.
+	ExpiresAt *time.Time
+	// RedirectType is HTTP status of the redirect, zero means the configured default.
+	RedirectType int
+	// PasswordHash is bcrypt hash of password protecting the url, empty if there's none.
+	PasswordHash string
+	// MaxClicks is how many redirects the url serves, zero means unlimited.
+	MaxClicks int64
+	// Title describes the url on its preview page.
+	Title string
+	// Preview makes redirects show preview page of the url first.
+	Preview bool
+}
+
+// URLToSave is a url saved in a batch by SaveURLs.
+type URLToSave struct {
+	URL   string
+	Alias string
+	Opts  URLOptions
+}
+
+// SaveResult is the outcome of saving one url of a batch. Err is
+// ErrURLExists if the alias is taken, even by an earlier url of the batch.
+type SaveResult struct {
+	ID  int64
+	Err error
+}
+
+const (
+	SortByCreatedAt = "created_at"
+	SortByAlias     = "alias"
 )
+
+// ListURLsParams describes a page of urls to list.
+type ListURLsParams struct {
+	SortBy string
+	Desc   bool
+	Limit  int
+	// After is the sort key of the last url of the previous page: creation
+	// time in RFC 3339 for SortByCreatedAt and alias for SortByAlias.
+	// Empty for the first page.
+	After string
+	// AfterID is id of the last url of the previous page. Urls with the same
+	// sort key are ordered by id: imported urls keep their creation time,
+	// and the same alias may be used on several domains.
+	AfterID int64
+}
+
+// ExportFilter describes a page of urls to export ordered by id.
+type ExportFilter struct {
+	// UserID limits export to urls of one user. Nil exports urls of all users.
+	UserID *int64
+	// AfterID is id of the last url of the previous page, zero for the first page.
+	AfterID int64
+	Limit   int
+}
+
+// ConflictPolicy tells ImportURLs what to do with urls whose alias is taken.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip keeps the stored url.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the stored url with the imported one.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictFail aborts the import with ErrURLExists, nothing is imported.
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ImportStats counts imported urls by outcome.
+type ImportStats struct {
+	Created int
+	Updated int
+	Skipped int
+}
+
+// URLUpdate describes changes to a stored url. Nil fields are left unchanged.
+type URLUpdate struct {
+	URL       *string
+	ExpiresAt *time.Time
+	// NoExpiry makes the url never expire, ExpiresAt is ignored then.
+	NoExpiry     bool
+	RedirectType *int
+}
+
+// Click is a single visit of a short link.
+type Click struct {
+	Domain    string
+	Alias     string
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+	IPHash    string
+}
+
+// ClickStats contains all-time clicks of a url and clicks grouped by time.
+type ClickStats struct {
+	Total   int64
+	Buckets []ClickBucket
+}
+
+type ClickBucket struct {
+	Start time.Time
+	Count int64
+}
+
+// APIKey is a long-lived key machine clients authenticate with on behalf
+// of a user. The key itself isn't stored.
+type APIKey struct {
+	ID     int64
+	UserID int64
+	Name   string
+	// Prefix is the start of the key shown to tell keys apart.
+	Prefix string
+	// Hash is hex encoded sha256 of the key, keys are looked up by it.
+	Hash string
+	// Scopes are operations the key is allowed to do.
+	Scopes []string
+	// CreatedAt is set by the caller.
+	CreatedAt time.Time
+}
@@ -0,0 +1,747 @@
+// Package storagetest contains conformance tests every storage.Storage
+// implementation must pass.
+package storagetest
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+// Factory returns a new empty storage. It's called once per subtest.
+type Factory func(t *testing.T) storage.Storage
+
+// Run runs the conformance suite against storages created by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.Storage)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"SaveExisting", testSaveExisting},
+		{"SaveURLs", testSaveURLs},
+		{"GetNotFound", testGetNotFound},
+		{"GetExpired", testGetExpired},
+		{"GetURLOwner", testGetURLOwner},
+		{"GetURLInfo", testGetURLInfo},
+		{"URLOptions", testURLOptions},
+		{"MaxClicks", testMaxClicks},
+		{"Domains", testDomains},
+		{"UpdateURL", testUpdateURL},
+		{"RenameURL", testRenameURL},
+		{"DeleteURL", testDeleteURL},
+		{"ListURLs", testListURLs},
+		{"ListURLsSameAlias", testListURLsSameAlias},
+		{"ListURLsImported", testListURLsImported},
+		{"ExportURLs", testExportURLs},
+		{"ImportURLs", testImportURLs},
+		{"ImportURLsFail", testImportURLsFail},
+		{"DeleteExpiredURLs", testDeleteExpiredURLs},
+		{"Clicks", testClicks},
+		{"APIKeys", testAPIKeys},
+		{"NextAliasID", testNextAliasID},
+		{"Ping", testPing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+func testSaveAndGet(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id, err := s.SaveURL(ctx, "https://example.com", "alias", 1, storage.URLOptions{})
+	require.NoError(t, err)
+	require.NotZero(t, id)
+
+	got, err := s.GetURL(ctx, "", "alias")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", got.URL)
+}
+
+func testSaveExisting(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "alias", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(ctx, "https://other.com", "alias", 2, storage.URLOptions{})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+}
+
+func testSaveURLs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "taken", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	results, err := s.SaveURLs(ctx, 2, []storage.URLToSave{
+		{URL: "https://first.com", Alias: "first"},
+		{URL: "https://other.com", Alias: "taken"},
+		{URL: "https://second.com", Alias: "second", Opts: storage.URLOptions{ExpiresAt: &expiresAt}},
+		{URL: "https://dup.com", Alias: "first"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	require.NoError(t, results[0].Err)
+	require.NotZero(t, results[0].ID)
+	require.ErrorIs(t, results[1].Err, storage.ErrURLExists)
+	require.NoError(t, results[2].Err)
+	require.NotZero(t, results[2].ID)
+	require.NotEqual(t, results[0].ID, results[2].ID)
+	require.ErrorIs(t, results[3].Err, storage.ErrURLExists)
+
+	got, err := s.GetURL(ctx, "", "first")
+	require.NoError(t, err)
+	require.Equal(t, "https://first.com", got.URL)
+
+	got, err = s.GetURL(ctx, "", "taken")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", got.URL)
+
+	owner, err := s.GetURLOwner(ctx, "", "second")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), owner)
+}
+
+func testGetNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.GetURL(ctx, "", "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func testGetExpired(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	_, err := s.SaveURL(ctx, "https://example.com", "expired", 1, storage.URLOptions{ExpiresAt: &past})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com", "alive", 1, storage.URLOptions{ExpiresAt: &future})
+	require.NoError(t, err)
+
+	_, err = s.GetURL(ctx, "", "expired")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+
+	_, err = s.GetURL(ctx, "", "alive")
+	require.NoError(t, err)
+}
+
+func testGetURLOwner(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "alias", 42, storage.URLOptions{})
+	require.NoError(t, err)
+
+	owner, err := s.GetURLOwner(ctx, "", "alias")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), owner)
+
+	_, err = s.GetURLOwner(ctx, "", "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func testGetURLInfo(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute).Truncate(time.Second).UTC()
+
+	id, err := s.SaveURL(ctx, "https://example.com", "alias", 7, storage.URLOptions{ExpiresAt: &past})
+	require.NoError(t, err)
+
+	// expired urls are still returned
+	u, err := s.GetURLInfo(ctx, "", "alias")
+	require.NoError(t, err)
+	require.Equal(t, id, u.ID)
+	require.Equal(t, "alias", u.Alias)
+	require.Equal(t, "https://example.com", u.URL)
+	require.Equal(t, int64(7), u.UserID)
+	require.WithinDuration(t, time.Now(), u.CreatedAt, time.Minute)
+	require.NotNil(t, u.ExpiresAt)
+	require.True(t, past.Equal(*u.ExpiresAt))
+
+	_, err = s.GetURLInfo(ctx, "", "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func testRenameURL(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "old", 1, storage.URLOptions{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://other.com", "taken", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveClicks(ctx, []storage.Click{{Alias: "old", ClickedAt: time.Now()}}))
+
+	require.ErrorIs(t, s.RenameURL(ctx, "", "old", "taken"), storage.ErrURLExists)
+	require.ErrorIs(t, s.RenameURL(ctx, "", "missing", "new"), storage.ErrURLNotFound)
+
+	require.NoError(t, s.RenameURL(ctx, "", "old", "new"))
+
+	_, err = s.GetURL(ctx, "", "old")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	got, err := s.GetURL(ctx, "", "new")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", got.URL)
+
+	stats, err := s.GetClickStats(ctx, "", "new", time.Now().Add(-time.Hour), time.Now().Add(time.Hour), time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.Total)
+}
+
+func testURLOptions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "default", 1, storage.URLOptions{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com", "permanent", 1, storage.URLOptions{RedirectType: 301})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com", "preview", 1, storage.URLOptions{Title: "Example", Preview: true})
+	require.NoError(t, err)
+	_, err = s.SaveURLs(ctx, 1, []storage.URLToSave{
+		{URL: "https://example.com", Alias: "protected", Opts: storage.URLOptions{RedirectType: 307, PasswordHash: "hash"}},
+	})
+	require.NoError(t, err)
+	_, err = s.ImportURLs(ctx, []storage.URL{
+		{
+			Alias: "imported", URL: "https://example.com", UserID: 1, CreatedAt: time.Now(),
+			RedirectType: 308, PasswordHash: "other", Title: "Imported", Preview: true,
+		},
+	}, storage.ConflictFail)
+	require.NoError(t, err)
+
+	want := map[string]storage.URLOptions{
+		"default":   {},
+		"permanent": {RedirectType: 301},
+		"preview":   {Title: "Example", Preview: true},
+		"protected": {RedirectType: 307, PasswordHash: "hash"},
+		"imported":  {RedirectType: 308, PasswordHash: "other", Title: "Imported", Preview: true},
+	}
+	for alias, opts := range want {
+		got, err := s.GetURL(ctx, "", alias)
+		require.NoError(t, err)
+		require.Equal(t, opts.RedirectType, got.RedirectType, alias)
+		require.Equal(t, opts.PasswordHash, got.PasswordHash, alias)
+		require.Equal(t, opts.Title, got.Title, alias)
+		require.Equal(t, opts.Preview, got.Preview, alias)
+
+		info, err := s.GetURLInfo(ctx, "", alias)
+		require.NoError(t, err)
+		require.Equal(t, opts.RedirectType, info.RedirectType, alias)
+		require.Equal(t, opts.PasswordHash, info.PasswordHash, alias)
+	}
+}
+
+func testMaxClicks(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "limited", 1, storage.URLOptions{MaxClicks: 2})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com", "unlimited", 1, storage.URLOptions{})
+	require.NoError(t, err)
+	_, err = s.ImportURLs(ctx, []storage.URL{
+		{Alias: "imported", URL: "https://example.com", UserID: 1, CreatedAt: time.Now(), MaxClicks: 3, UsedClicks: 2},
+	}, storage.ConflictFail)
+	require.NoError(t, err)
+
+	got, err := s.GetURL(ctx, "", "limited")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), got.MaxClicks)
+
+	require.NoError(t, s.ConsumeClick(ctx, "", "limited"))
+	require.NoError(t, s.ConsumeClick(ctx, "", "limited"))
+	require.ErrorIs(t, s.ConsumeClick(ctx, "", "limited"), storage.ErrURLExhausted)
+
+	_, err = s.GetURL(ctx, "", "limited")
+	require.ErrorIs(t, err, storage.ErrURLExhausted)
+
+	info, err := s.GetURLInfo(ctx, "", "limited")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), info.UsedClicks)
+
+	require.NoError(t, s.ConsumeClick(ctx, "", "imported"))
+	require.ErrorIs(t, s.ConsumeClick(ctx, "", "imported"), storage.ErrURLExhausted)
+
+	require.NoError(t, s.ConsumeClick(ctx, "", "unlimited"))
+	require.ErrorIs(t, s.ConsumeClick(ctx, "", "missing"), storage.ErrURLNotFound)
+
+	// exhausted urls are kept until purge after the last click like
+	// expired ones until purge after expiry
+	deleted, err := s.DeleteExpiredURLs(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	_, err = s.GetURL(ctx, "", "limited")
+	require.ErrorIs(t, err, storage.ErrURLExhausted)
+
+	deleted, err = s.DeleteExpiredURLs(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+
+	_, err = s.GetURL(ctx, "", "unlimited")
+	require.NoError(t, err)
+}
+
+func testDomains(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "alias", 1, storage.URLOptions{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.org", "alias", 2, storage.URLOptions{Domain: "go.example"})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.net", "alias", 2, storage.URLOptions{Domain: "go.example"})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+
+	got, err := s.GetURL(ctx, "", "alias")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", got.URL)
+	require.Empty(t, got.Domain)
+
+	got, err = s.GetURL(ctx, "go.example", "alias")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.org", got.URL)
+	require.Equal(t, "go.example", got.Domain)
+
+	_, err = s.GetURL(ctx, "other.example", "alias")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	owner, err := s.GetURLOwner(ctx, "go.example", "alias")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), owner)
+
+	require.NoError(t, s.RenameURL(ctx, "go.example", "alias", "renamed"))
+	_, err = s.GetURL(ctx, "", "alias")
+	require.NoError(t, err)
+	_, err = s.GetURL(ctx, "go.example", "renamed")
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteURL(ctx, "", "alias"))
+	require.ErrorIs(t, s.DeleteURL(ctx, "", "renamed"), storage.ErrURLNotFound)
+	_, err = s.GetURL(ctx, "go.example", "renamed")
+	require.NoError(t, err)
+
+	_, err = s.ImportURLs(ctx, []storage.URL{
+		{Domain: "go.example", Alias: "renamed", URL: "https://example.net", UserID: 1, CreatedAt: time.Now()},
+		{Alias: "renamed", URL: "https://example.net", UserID: 1, CreatedAt: time.Now()},
+	}, storage.ConflictSkip)
+	require.NoError(t, err)
+
+	got, err = s.GetURL(ctx, "go.example", "renamed")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.org", got.URL)
+	got, err = s.GetURL(ctx, "", "renamed")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.net", got.URL)
+}
+
+func testUpdateURL(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "alias", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	newURL := "https://new.example.com"
+	require.NoError(t, s.UpdateURL(ctx, "", "alias", storage.URLUpdate{URL: &newURL}))
+
+	got, err := s.GetURL(ctx, "", "alias")
+	require.NoError(t, err)
+	require.Equal(t, newURL, got.URL)
+
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, s.UpdateURL(ctx, "", "alias", storage.URLUpdate{ExpiresAt: &past}))
+
+	_, err = s.GetURL(ctx, "", "alias")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+
+	redirectType := 301
+	require.NoError(t, s.UpdateURL(ctx, "", "alias", storage.URLUpdate{NoExpiry: true, RedirectType: &redirectType}))
+
+	got, err = s.GetURL(ctx, "", "alias")
+	require.NoError(t, err)
+	require.Nil(t, got.ExpiresAt)
+	require.Equal(t, redirectType, got.RedirectType)
+
+	err = s.UpdateURL(ctx, "", "missing", storage.URLUpdate{URL: &newURL})
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func testDeleteURL(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "alias", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteURL(ctx, "", "alias"))
+
+	_, err = s.GetURL(ctx, "", "alias")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	err = s.DeleteURL(ctx, "", "alias")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// alias is free again
+	_, err = s.SaveURL(ctx, "https://example.com", "alias", 1, storage.URLOptions{})
+	require.NoError(t, err)
+}
+
+func testListURLs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	for _, alias := range []string{"b", "a", "C", "d"} {
+		_, err := s.SaveURL(ctx, "https://example.com/"+alias, alias, 1, storage.URLOptions{})
+		require.NoError(t, err)
+	}
+	_, err := s.SaveURL(ctx, "https://example.com/other", "other", 2, storage.URLOptions{})
+	require.NoError(t, err)
+
+	// by creation, newest first
+	page, err := s.ListURLs(ctx, 1, storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Desc: true, Limit: 3})
+	require.NoError(t, err)
+	require.Equal(t, []string{"d", "C", "a"}, aliases(page))
+
+	page, err = s.ListURLs(ctx, 1, storage.ListURLsParams{
+		SortBy:  storage.SortByCreatedAt,
+		Desc:    true,
+		Limit:   3,
+		After:   page[2].CreatedAt.Format(time.RFC3339Nano),
+		AfterID: page[2].ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, aliases(page))
+
+	// by alias, bytewise
+	page, err = s.ListURLs(ctx, 1, storage.ListURLsParams{SortBy: storage.SortByAlias, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"C", "a"}, aliases(page))
+
+	page, err = s.ListURLs(ctx, 1, storage.ListURLsParams{SortBy: storage.SortByAlias, Limit: 2, After: "a", AfterID: page[1].ID})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "d"}, aliases(page))
+
+	require.Equal(t, "https://example.com/b", page[0].URL)
+	require.Equal(t, int64(1), page[0].UserID)
+	require.False(t, page[0].CreatedAt.IsZero())
+
+	page, err = s.ListURLs(ctx, 3, storage.ListURLsParams{SortBy: storage.SortByAlias, Limit: 2})
+	require.NoError(t, err)
+	require.Empty(t, page)
+}
+
+func testListURLsSameAlias(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	for _, d := range []string{"", "go.example", "s.example"} {
+		_, err := s.SaveURL(ctx, "https://example.com", "same", 1, storage.URLOptions{Domain: d})
+		require.NoError(t, err)
+	}
+
+	// every url is listed once although pages end in the middle of the alias
+	for _, desc := range []bool{false, true} {
+		params := storage.ListURLsParams{SortBy: storage.SortByAlias, Desc: desc, Limit: 2}
+
+		var domains []string
+		for {
+			page, err := s.ListURLs(ctx, 1, params)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+
+			for _, u := range page {
+				domains = append(domains, u.Domain)
+			}
+
+			last := page[len(page)-1]
+			params.After, params.AfterID = last.Alias, last.ID
+		}
+
+		require.ElementsMatch(t, []string{"", "go.example", "s.example"}, domains)
+		require.Len(t, domains, 3)
+	}
+}
+
+func testListURLsImported(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com/new", "new", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	// imported urls get new ids but keep their creation time
+	_, err = s.ImportURLs(ctx, []storage.URL{
+		{Alias: "old", URL: "https://example.com/old", UserID: 1, CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Alias: "older", URL: "https://example.com/older", UserID: 1, CreatedAt: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Alias: "middle", URL: "https://example.com/middle", UserID: 1, CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 500, time.UTC)},
+	}, storage.ConflictSkip)
+	require.NoError(t, err)
+
+	for _, desc := range []bool{false, true} {
+		params := storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Desc: desc, Limit: 1}
+
+		var got []string
+		for {
+			page, err := s.ListURLs(ctx, 1, params)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+
+			got = append(got, aliases(page)...)
+
+			last := page[len(page)-1]
+			params.After, params.AfterID = last.CreatedAt.Format(time.RFC3339Nano), last.ID
+		}
+
+		want := []string{"older", "old", "middle", "new"}
+		if desc {
+			slices.Reverse(want)
+		}
+		require.Equal(t, want, got)
+	}
+}
+
+func testExportURLs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	var ids []int64
+	for i, userID := range []int64{1, 2, 1, 1} {
+		id, err := s.SaveURL(ctx, "https://example.com", "alias"+strconv.Itoa(i), userID, storage.URLOptions{})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	page, err := s.ExportURLs(ctx, storage.ExportFilter{Limit: 3})
+	require.NoError(t, err)
+	require.Equal(t, []string{"alias0", "alias1", "alias2"}, aliases(page))
+
+	page, err = s.ExportURLs(ctx, storage.ExportFilter{AfterID: page[2].ID, Limit: 3})
+	require.NoError(t, err)
+	require.Equal(t, []string{"alias3"}, aliases(page))
+
+	userID := int64(1)
+	page, err = s.ExportURLs(ctx, storage.ExportFilter{UserID: &userID, AfterID: ids[0], Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"alias2", "alias3"}, aliases(page))
+}
+
+func testImportURLs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://old.com", "taken", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	imported := []storage.URL{
+		{Alias: "new", URL: "https://new.com", UserID: 7, CreatedAt: createdAt, Clicks: 3, ExpiresAt: &expiresAt},
+		{Alias: "taken", URL: "https://imported.com", UserID: 8, CreatedAt: createdAt},
+	}
+
+	stats, err := s.ImportURLs(ctx, imported, storage.ConflictSkip)
+	require.NoError(t, err)
+	require.Equal(t, storage.ImportStats{Created: 1, Skipped: 1}, stats)
+
+	got, err := s.GetURL(ctx, "", "taken")
+	require.NoError(t, err)
+	require.Equal(t, "https://old.com", got.URL)
+
+	urls, err := s.ListURLs(ctx, 7, storage.ListURLsParams{SortBy: storage.SortByCreatedAt, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	require.Equal(t, "https://new.com", urls[0].URL)
+	require.Equal(t, int64(3), urls[0].Clicks)
+	require.True(t, createdAt.Equal(urls[0].CreatedAt))
+	require.NotNil(t, urls[0].ExpiresAt)
+	require.True(t, expiresAt.Equal(*urls[0].ExpiresAt))
+
+	stats, err = s.ImportURLs(ctx, imported[1:], storage.ConflictOverwrite)
+	require.NoError(t, err)
+	require.Equal(t, storage.ImportStats{Updated: 1}, stats)
+
+	got, err = s.GetURL(ctx, "", "taken")
+	require.NoError(t, err)
+	require.Equal(t, "https://imported.com", got.URL)
+
+	owner, err := s.GetURLOwner(ctx, "", "taken")
+	require.NoError(t, err)
+	require.Equal(t, int64(8), owner)
+}
+
+func testImportURLsFail(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://old.com", "taken", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	_, err = s.ImportURLs(ctx, []storage.URL{
+		{Alias: "new", URL: "https://new.com", UserID: 1, CreatedAt: time.Now()},
+		{Alias: "taken", URL: "https://imported.com", UserID: 1, CreatedAt: time.Now()},
+	}, storage.ConflictFail)
+	require.ErrorIs(t, err, storage.ErrURLExists)
+
+	// the whole import is rolled back
+	_, err = s.GetURL(ctx, "", "new")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func testDeleteExpiredURLs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	_, err := s.SaveURL(ctx, "https://example.com", "expired", 1, storage.URLOptions{ExpiresAt: &past})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com", "alive", 1, storage.URLOptions{ExpiresAt: &future})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com", "forever", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	deleted, err := s.DeleteExpiredURLs(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	_, err = s.GetURL(ctx, "", "expired")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = s.GetURL(ctx, "", "alive")
+	require.NoError(t, err)
+	_, err = s.GetURL(ctx, "", "forever")
+	require.NoError(t, err)
+}
+
+func testClicks(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "https://example.com", "alias", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = s.SaveClicks(ctx, []storage.Click{
+		{Alias: "alias", ClickedAt: start.Add(10 * time.Minute), Referrer: "r", UserAgent: "ua", IPHash: "h"},
+		{Alias: "alias", ClickedAt: start.Add(20 * time.Minute)},
+		{Alias: "alias", ClickedAt: start.Add(2*time.Hour + time.Minute)},
+		{Alias: "alias", ClickedAt: start.Add(48 * time.Hour)},
+		{Alias: "missing", ClickedAt: start},
+	})
+	require.NoError(t, err)
+
+	stats, err := s.GetClickStats(ctx, "", "alias", start, start.Add(24*time.Hour), time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), stats.Total)
+	require.Equal(t, []storage.ClickBucket{
+		{Start: start, Count: 2},
+		{Start: start.Add(2 * time.Hour), Count: 1},
+	}, stats.Buckets)
+
+	page, err := s.ListURLs(ctx, 1, storage.ListURLsParams{SortBy: storage.SortByAlias, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), page[0].Clicks)
+
+	_, err = s.GetClickStats(ctx, "", "missing", start, start.Add(time.Hour), time.Hour)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// clicks are removed with their url
+	require.NoError(t, s.DeleteURL(ctx, "", "alias"))
+	_, err = s.SaveURL(ctx, "https://example.com", "alias", 1, storage.URLOptions{})
+	require.NoError(t, err)
+
+	stats, err = s.GetClickStats(ctx, "", "alias", start, start.Add(24*time.Hour), time.Hour)
+	require.NoError(t, err)
+	require.Zero(t, stats.Total)
+	require.Empty(t, stats.Buckets)
+}
+
+func testAPIKeys(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	keys, err := s.ListAPIKeys(ctx, 1)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	createdAt := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	ciID, err := s.SaveAPIKey(ctx, storage.APIKey{
+		UserID:    1,
+		Name:      "ci",
+		Prefix:    "us_abcd",
+		Hash:      "hash1",
+		Scopes:    []string{"urls:read", "urls:write"},
+		CreatedAt: createdAt,
+	})
+	require.NoError(t, err)
+	_, err = s.SaveAPIKey(ctx, storage.APIKey{UserID: 1, Prefix: "us_efgh", Hash: "hash2"})
+	require.NoError(t, err)
+	otherID, err := s.SaveAPIKey(ctx, storage.APIKey{UserID: 2, Prefix: "us_ijkl", Hash: "hash3"})
+	require.NoError(t, err)
+	_, err = s.SaveAPIKey(ctx, storage.APIKey{UserID: 2, Prefix: "us_mnop", Hash: "hash1"})
+	require.Error(t, err)
+
+	key, err := s.GetAPIKey(ctx, "hash1")
+	require.NoError(t, err)
+	require.Equal(t, ciID, key.ID)
+	require.Equal(t, int64(1), key.UserID)
+	require.Equal(t, "ci", key.Name)
+	require.Equal(t, "us_abcd", key.Prefix)
+	require.Equal(t, []string{"urls:read", "urls:write"}, key.Scopes)
+	require.True(t, createdAt.Equal(key.CreatedAt), key.CreatedAt)
+
+	_, err = s.GetAPIKey(ctx, "missing")
+	require.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+
+	keys, err = s.ListAPIKeys(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "hash1", keys[0].Hash)
+	require.Equal(t, "hash2", keys[1].Hash)
+	require.Empty(t, keys[1].Scopes)
+
+	// keys of other users can't be revoked
+	require.ErrorIs(t, s.DeleteAPIKey(ctx, 1, otherID), storage.ErrAPIKeyNotFound)
+
+	require.NoError(t, s.DeleteAPIKey(ctx, 1, ciID))
+	require.ErrorIs(t, s.DeleteAPIKey(ctx, 1, ciID), storage.ErrAPIKeyNotFound)
+	_, err = s.GetAPIKey(ctx, "hash1")
+	require.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+
+	keys, err = s.ListAPIKeys(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+}
+
+func testNextAliasID(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	first, err := s.NextAliasID(ctx)
+	require.NoError(t, err)
+	require.Positive(t, first)
+
+	second, err := s.NextAliasID(ctx)
+	require.NoError(t, err)
+	require.Greater(t, second, first)
+}
+
+func testPing(t *testing.T, s storage.Storage) {
+	require.NoError(t, s.Ping(context.Background()))
+}
+
+func aliases(urls []storage.URL) []string {
+	res := make([]string, 0, len(urls))
+	for _, u := range urls {
+		res = append(res, u.Alias)
+	}
+	return res
+}